@@ -0,0 +1,527 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kabilan108/diffgpt/internal/config"
+	"github.com/kabilan108/diffgpt/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	examplesGlobal      bool
+	examplesRepo        string
+	examplesSet         string
+	examplesAll         bool
+	examplesDiffFile    string
+	examplesMessage     string
+	examplesMessageFile string
+	examplesEditDiff    bool
+	examplesDryRun      bool
+	examplesReplace     bool
+)
+
+var examplesCmd = &cobra.Command{
+	Use:   "examples",
+	Short: "manage learned commit examples",
+	Long: `inspect and curate the commit examples stored by 'diffgpt learn'.
+
+examples are addressed by their 1-based index as shown by 'diffgpt examples list'.
+commands operate on the current repository unless --repo, --set or --global is given.`,
+}
+
+var examplesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list stored examples",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if examplesAll {
+			keys, err := loadAllExampleKeys()
+			if err != nil {
+				return err
+			}
+			for _, key := range keys {
+				s, err := config.LoadStoredExamples(key)
+				if err != nil {
+					return err
+				}
+				status := ""
+				if isStaleKey(s) {
+					status = " (missing)"
+				}
+				fmt.Printf("%s: %d examples%s\n", key, len(s.Examples), status)
+			}
+			return nil
+		}
+
+		storageKey, _, err := examplesTarget()
+		if err != nil {
+			return err
+		}
+		examples, err := config.LoadExamples(storageKey)
+		if err != nil {
+			return err
+		}
+		if len(examples) == 0 {
+			fmt.Printf("No examples found for '%s'.\n", storageKey)
+			return nil
+		}
+		for i, ex := range examples {
+			diffLines := strings.Count(ex.Diff, "\n") + 1
+			scoreInfo := ""
+			if ex.Score > 0 {
+				scoreInfo = fmt.Sprintf(", score %.2f", ex.Score)
+			}
+			fmt.Printf("[%d] %s (%d diff lines%s)\n", i+1, exampleSubject(ex), diffLines, scoreInfo)
+		}
+		return nil
+	},
+}
+
+var examplesShowCmd = &cobra.Command{
+	Use:   "show <index>",
+	Short: "show the full message and diff of an example",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		storageKey, _, err := examplesTarget()
+		if err != nil {
+			return err
+		}
+		examples, err := config.LoadExamples(storageKey)
+		if err != nil {
+			return err
+		}
+		idx, err := parseExampleIndex(args[0], len(examples))
+		if err != nil {
+			return err
+		}
+		ex := examples[idx]
+		fmt.Printf("message:\n%s\n\ndiff:\n%s\n", ex.Message, ex.Diff)
+		return nil
+	},
+}
+
+var examplesRmCmd = &cobra.Command{
+	Use:   "rm <index>...",
+	Short: "remove one or more examples",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		removed := 0
+		storageKey, err := updateExamplesTarget(func(s *config.StoredExamples) error {
+			remove := make(map[int]bool, len(args))
+			for _, arg := range args {
+				idx, err := parseExampleIndex(arg, len(s.Examples))
+				if err != nil {
+					return err
+				}
+				remove[idx] = true
+			}
+
+			kept := make([]config.Example, 0, len(s.Examples)-len(remove))
+			for i, ex := range s.Examples {
+				if !remove[i] {
+					kept = append(kept, ex)
+				}
+			}
+			s.Examples = kept
+			removed = len(remove)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Removed %d examples from '%s'.\n", removed, storageKey)
+		return nil
+	},
+}
+
+var examplesAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "add a hand-curated diff and message pair",
+	Long: `adds an example from a diff file and a commit message.
+
+use '--diff -' to read the diff from stdin, e.g.
+  git show --format= HEAD | diffgpt examples add --diff - -m "feat: add login"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if examplesDiffFile == "" {
+			return fmt.Errorf("a diff is required, use --diff <file> or --diff -")
+		}
+		if examplesMessage != "" && examplesMessageFile != "" {
+			return fmt.Errorf("--message and --message-file are mutually exclusive")
+		}
+
+		diff, err := readInput(examplesDiffFile)
+		if err != nil {
+			return fmt.Errorf("failed to read diff: %w", err)
+		}
+		message := examplesMessage
+		if examplesMessageFile != "" {
+			message, err = readInput(examplesMessageFile)
+			if err != nil {
+				return fmt.Errorf("failed to read message: %w", err)
+			}
+		}
+		diff = strings.TrimRight(diff, "\n")
+		message = strings.TrimSpace(message)
+		if strings.TrimSpace(diff) == "" {
+			return fmt.Errorf("diff is empty")
+		}
+		if message == "" {
+			return fmt.Errorf("a message is required, use --message or --message-file")
+		}
+
+		count := 0
+		storageKey, err := updateExamplesTarget(func(s *config.StoredExamples) error {
+			s.Examples = append(s.Examples, config.Example{
+				Diff:    diff,
+				Message: message,
+			})
+			count = len(s.Examples)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Added example [%d] to '%s'.\n", count, storageKey)
+		return nil
+	},
+}
+
+var examplesEditCmd = &cobra.Command{
+	Use:   "edit <index>",
+	Short: "edit the message (or diff) of an example in your editor",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		storageKey, _, err := examplesTarget()
+		if err != nil {
+			return err
+		}
+		examples, err := config.LoadExamples(storageKey)
+		if err != nil {
+			return err
+		}
+		idx, err := parseExampleIndex(args[0], len(examples))
+		if err != nil {
+			return err
+		}
+		original := examples[idx]
+
+		// the store isn't locked while the editor is open, so the edit is
+		// applied to whichever example still matches the original afterwards
+		content, pattern := original.Message, "diffgpt-message-*.txt"
+		if examplesEditDiff {
+			content, pattern = original.Diff, "diffgpt-diff-*.diff"
+		}
+		edited, err := editInEditor(content, pattern)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(edited) == "" {
+			fmt.Fprintln(os.Stderr, "Empty content, example left unchanged")
+			return nil
+		}
+
+		_, err = updateExamplesTarget(func(s *config.StoredExamples) error {
+			for i, ex := range s.Examples {
+				if ex != original {
+					continue
+				}
+				if examplesEditDiff {
+					s.Examples[i].Diff = strings.TrimRight(edited, "\n")
+				} else {
+					s.Examples[i].Message = strings.TrimSpace(edited)
+				}
+				return nil
+			}
+			return fmt.Errorf("example [%d] was changed or removed while editing", idx+1)
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Updated example [%d] in '%s'.\n", idx+1, storageKey)
+		return nil
+	},
+}
+
+var examplesPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "remove examples for repositories that no longer exist on disk",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keys, err := loadAllExampleKeys()
+		if err != nil {
+			return err
+		}
+
+		pruned := 0
+		for _, key := range keys {
+			s, err := config.LoadStoredExamples(key)
+			if err != nil {
+				return err
+			}
+			if !isStaleKey(s) {
+				continue
+			}
+			fmt.Printf("Pruning %d examples for '%s'\n", len(s.Examples), key)
+			if !examplesDryRun {
+				if _, err := config.DeleteExamples(key); err != nil {
+					return fmt.Errorf("failed to prune examples for '%s': %w", key, err)
+				}
+			}
+			pruned++
+		}
+		if pruned == 0 {
+			fmt.Println("Nothing to prune.")
+		}
+		return nil
+	},
+}
+
+var examplesExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export examples as a shareable bundle",
+	Long: `writes the examples for the target as a JSON bundle to stdout.
+
+the bundle does not record where the examples were stored, so it can be imported
+into any checkout of the same repository, e.g.
+  diffgpt examples export --repo . > style.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		storageKey, _, err := examplesTarget()
+		if err != nil {
+			return err
+		}
+		examples, err := config.LoadExamples(storageKey)
+		if err != nil {
+			return err
+		}
+		if len(examples) == 0 {
+			return fmt.Errorf("no examples found for '%s'", storageKey)
+		}
+
+		if err := config.WriteBundle(os.Stdout, config.NewBundle(storageKey, examples)); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d examples from '%s'.\n", len(examples), storageKey)
+		return nil
+	},
+}
+
+var examplesImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "import examples from a bundle",
+	Long: `reads a bundle created by 'diffgpt examples export' and stores its examples for the target.
+
+examples are merged with the existing ones, skipping duplicates, unless --replace is given.
+if [file] is omitted or '-', the bundle is read from stdin.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in := io.Reader(os.Stdin)
+		if len(args) > 0 && args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to open bundle: %w", err)
+			}
+			defer f.Close()
+			in = f
+		}
+		bundle, err := config.ReadBundle(in)
+		if err != nil {
+			return err
+		}
+
+		added := len(bundle.Examples)
+		storageKey, err := updateExamplesTarget(func(s *config.StoredExamples) error {
+			if examplesReplace {
+				s.Examples = bundle.Examples
+			} else {
+				s.Examples, added = config.MergeExamples(s.Examples, bundle.Examples)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Imported %d examples into '%s'.\n", added, storageKey)
+		return nil
+	},
+}
+
+// examplesTarget resolves the storage key selected by --global, --set and
+// --repo, along with the repository root (empty unless a repository is selected).
+func examplesTarget() (string, string, error) {
+	// loading the config migrates any examples still stored in it
+	if _, err := config.LoadConfig(); err != nil {
+		return "", "", fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if examplesGlobal {
+		return globalKey, "", nil
+	}
+	if examplesSet != "" {
+		return styleSetKey(examplesSet), "", nil
+	}
+	repoRoot, err := git.GetRepoRoot(examplesRepo)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to determine repository root: %w", err)
+	}
+	storageKey, err := storageKeyFor(repoRoot, false)
+	if err != nil {
+		return "", "", err
+	}
+	return storageKey, repoRoot, nil
+}
+
+// updateExamplesTarget applies fn to the examples of the selected target and
+// returns its storage key.
+func updateExamplesTarget(fn func(s *config.StoredExamples) error) (string, error) {
+	storageKey, repoRoot, err := examplesTarget()
+	if err != nil {
+		return "", err
+	}
+	err = config.UpdateExamples(storageKey, func(s *config.StoredExamples) error {
+		if err := fn(s); err != nil {
+			return err
+		}
+		if repoRoot != "" {
+			s.AddCheckout(repoRoot)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to update examples for '%s': %w", storageKey, err)
+	}
+	return storageKey, nil
+}
+
+func loadAllExampleKeys() ([]string, error) {
+	if _, err := config.LoadConfig(); err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	keys, err := config.ListExampleKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stored examples: %w", err)
+	}
+	return keys, nil
+}
+
+// parseExampleIndex converts a 1-based index argument into a slice index.
+func parseExampleIndex(arg string, n int) (int, error) {
+	idx, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, fmt.Errorf("invalid example index %q", arg)
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("no examples stored for this target")
+	}
+	if idx < 1 || idx > n {
+		return 0, fmt.Errorf("example index %d out of range (1-%d)", idx, n)
+	}
+	return idx - 1, nil
+}
+
+func exampleSubject(ex config.Example) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(ex.Message), "\n")
+	return subject
+}
+
+// isStaleKey reports whether the stored examples belong to a repository that
+// no longer exists on disk, either because the path key is gone or because
+// none of its recorded checkouts are left.
+func isStaleKey(s *config.StoredExamples) bool {
+	if s.Key == globalKey {
+		return false
+	}
+	paths := s.Checkouts
+	if filepath.IsAbs(s.Key) {
+		paths = append(paths, s.Key)
+	}
+	if len(paths) == 0 {
+		return false
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			return false
+		}
+	}
+	return true
+}
+
+// readInput reads the contents of path, or stdin if path is "-".
+func readInput(path string) (string, error) {
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		return string(data), err
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+// editInEditor opens content in the user's git editor and returns the result.
+func editInEditor(content, pattern string) (string, error) {
+	editor, err := git.GetEditor("")
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	// run through the shell like git does so editors with arguments work
+	editorCmd := exec.Command("sh", "-c", editor+` "$@"`, editor, f.Name())
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+	if err := editorCmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited file: %w", err)
+	}
+	return string(data), nil
+}
+
+func init() {
+	rootCmd.AddCommand(examplesCmd)
+	examplesCmd.AddCommand(examplesListCmd, examplesShowCmd, examplesRmCmd,
+		examplesAddCmd, examplesEditCmd, examplesPruneCmd, examplesExportCmd, examplesImportCmd)
+
+	examplesCmd.PersistentFlags().BoolVarP(&examplesGlobal, "global", "g", false, "Operate on global examples instead of a repository")
+	examplesCmd.PersistentFlags().StringVarP(&examplesRepo, "repo", "r", "", "Repository whose examples to operate on (default: current repository)")
+	examplesCmd.PersistentFlags().StringVar(&examplesSet, "set", "", "Named style set to operate on instead of a repository")
+	examplesCmd.MarkFlagsMutuallyExclusive("global", "repo", "set")
+
+	examplesListCmd.Flags().BoolVarP(&examplesAll, "all", "a", false, "List every stored target with its example count")
+
+	examplesAddCmd.Flags().StringVar(&examplesDiffFile, "diff", "", "File containing the diff ('-' for stdin)")
+	examplesAddCmd.Flags().StringVarP(&examplesMessage, "message", "m", "", "Commit message for the example")
+	examplesAddCmd.Flags().StringVarP(&examplesMessageFile, "message-file", "F", "", "File containing the commit message ('-' for stdin)")
+
+	examplesEditCmd.Flags().BoolVar(&examplesEditDiff, "diff", false, "Edit the diff instead of the message")
+
+	examplesPruneCmd.Flags().BoolVarP(&examplesDryRun, "dry-run", "n", false, "Only show what would be pruned")
+
+	examplesImportCmd.Flags().BoolVar(&examplesReplace, "replace", false, "Replace existing examples instead of merging")
+}
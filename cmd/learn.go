@@ -2,13 +2,20 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/kabilan108/diffgpt/internal/config"
 	"github.com/kabilan108/diffgpt/internal/git"
+	"github.com/kabilan108/diffgpt/internal/llm"
+	"github.com/kabilan108/diffgpt/internal/score"
 	"github.com/spf13/cobra"
 )
 
@@ -17,10 +24,41 @@ var (
 	learnStart  string
 	learnClear  bool
 	learnCount  int
+	learnUpdate bool
+	learnMax    int
+	learnJobs   int
+	learnSet    string
+
+	learnKeep     int
+	learnMinScore float64
+	learnJudge    bool
+
+	learnAuthor           string
+	learnSince            string
+	learnUntil            string
+	learnPaths            []string
+	learnNoMerges         bool
+	learnMinSubjectLength int
+	learnExcludeMessage   string
+	learnConventionalOnly bool
+)
+
+const (
+	globalKey      = "global"
+	styleSetPrefix = "set:"
 )
 
+// styleSetKey returns the storage key of a named style set, or an empty
+// string if name is empty.
+func styleSetKey(name string) string {
+	if name == "" {
+		return ""
+	}
+	return styleSetPrefix + name
+}
+
 var learnCmd = &cobra.Command{
-	Use:   "learn [path]",
+	Use:   "learn [path...]",
 	Short: "learn commit style from a repository",
 	Long: `scans a git repository's history to learn its commit message style.
 
@@ -29,41 +67,72 @@ fetches recent commits and their associated diffs and stores them as examples.
 examples can be stored globally or per-repository and are used for in-context learning
 during commit message generation.
 
-if [repo-path] is omitted, learns from the current repository.`,
-	Args: cobra.MaximumNArgs(1), // 0 or 1 argument for repo path
+with --set, examples are sampled from one or more repositories into a named style set
+that can be used from any repository with 'diffgpt --style <name>', e.g.
+  diffgpt learn --set backend ~/src/billing ~/src/auth ~/src/search
+
+with --update, the commits made since the last learn are fetched (--count only
+applies the first time) and added to the existing examples, keeping at most --max
+of the highest scoring. only the newest --max commits are fetched unless --keep or
+--min-score curate them. this is cheap enough to run from a post-merge hook or cron
+job.
+
+use the filter flags to only learn from commits whose style you want reproduced, e.g.
+  diffgpt learn --no-merges --min-subject-length 15 --exclude-message '^wip|^fixup!'
+
+every learned example is scored on subject length, conventional commit conformance,
+how much detail the message gives for the size of the diff and duplicate subjects.
+--judge additionally asks the llm to rate each example. use --keep and --min-score to
+only store the best examples.
+
+if [path] is omitted, learns from the current repository. --count applies to each repository.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if learnSet == "" && len(args) > 1 {
+			return fmt.Errorf("learning from several repositories requires --set")
+		}
+		return nil
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, err := config.LoadConfig()
-		if err != nil {
-			return fmt.Errorf("failed to load configuration: %w", err)
+		if learnSet != "" && learnGlobal {
+			return fmt.Errorf("--set and --global are mutually exclusive")
 		}
 
-		// Determine repository path
-		repoPathArg := ""
-		if len(args) > 0 {
-			repoPathArg = args[0]
+		// loading the config migrates any examples still stored in it
+		if _, err := config.LoadConfig(); err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
 		}
-		repoRoot, err := git.GetRepoRoot(repoPathArg)
-		if err != nil {
-			return fmt.Errorf("failed to determine repository root: %w", err)
+
+		// Determine repository paths
+		repoPathArgs := args
+		if len(repoPathArgs) == 0 {
+			repoPathArgs = []string{""}
 		}
-		absRepoRoot, err := filepath.Abs(repoRoot) // Use absolute path for consistency
-		if err != nil {
-			return fmt.Errorf("failed to get absolute path for repository root: %w", err)
+		repoRoots := make([]string, 0, len(repoPathArgs))
+		for _, repoPathArg := range repoPathArgs {
+			repoRoot, err := git.GetRepoRoot(repoPathArg)
+			if err != nil {
+				return fmt.Errorf("failed to determine repository root: %w", err)
+			}
+			repoRoots = append(repoRoots, repoRoot)
 		}
 
 		// Determine storage key
-		storageKey := absRepoRoot
-		if learnGlobal {
-			storageKey = "global"
+		storageKey := styleSetKey(learnSet)
+		if learnSet == "" {
+			var err error
+			storageKey, err = storageKeyFor(repoRoots[0], learnGlobal)
+			if err != nil {
+				return err
+			}
 		}
 
 		// Handle --clear flag
 		if learnClear {
-			if _, exists := cfg.Examples[storageKey]; exists {
-				delete(cfg.Examples, storageKey)
-				if err := config.SaveConfig(cfg); err != nil {
-					return fmt.Errorf("failed to save cleared configuration: %w", err)
-				}
+			existed, err := config.DeleteExamples(storageKey)
+			if err != nil {
+				return fmt.Errorf("failed to clear examples: %w", err)
+			}
+			if existed {
 				fmt.Printf("Cleared examples for '%s'\n", storageKey)
 			} else {
 				fmt.Printf("No examples found for '%s' to clear.\n", storageKey)
@@ -71,65 +140,347 @@ if [repo-path] is omitted, learns from the current repository.`,
 			return nil // Done after clearing
 		}
 
-		// Fetch commit log
-		fmt.Printf("Fetching last %d commits from '%s'...\n", learnCount, repoRoot)
-		commits, err := git.GetCommitLog(repoRoot, learnStart, learnCount)
-		if err != nil {
-			return fmt.Errorf("failed to fetch commit log: %w", err)
+		filter := git.LogFilter{
+			Author:           learnAuthor,
+			Since:            learnSince,
+			Until:            learnUntil,
+			Paths:            learnPaths,
+			NoMerges:         learnNoMerges,
+			MinSubjectLength: learnMinSubjectLength,
+			ConventionalOnly: learnConventionalOnly,
 		}
-		if len(commits) == 0 {
-			fmt.Println("No commits found matching criteria.")
-			return nil
+		if learnExcludeMessage != "" {
+			var err error
+			filter.ExcludeMessage, err = regexp.Compile(learnExcludeMessage)
+			if err != nil {
+				return fmt.Errorf("invalid --exclude-message pattern: %w", err)
+			}
 		}
 
-		// Fetch diffs and full messages
-		fmt.Println("Processing commits to extract diffs and messages...")
-		learnedExamples := make([]config.Example, 0, len(commits))
-		for i, commit := range commits {
-			fmt.Printf("  [%d/%d] Processing commit %s (%s)\n", i+1, len(commits), commit.SHA[:7], commit.Subject)
-			diff, err := git.GetDiffForCommit(repoRoot, commit.SHA)
+		stored := &config.StoredExamples{}
+		if learnUpdate {
+			var err error
+			stored, err = config.LoadStoredExamples(storageKey)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to get diff for commit %s: %v\n", commit.SHA, err)
-				continue // Skip this commit if diff fails
-			}
-			// Skip empty diffs (e.g., merge commits without changes)
-			if strings.TrimSpace(diff) == "" {
-				fmt.Printf("  Skipping commit %s: empty diff\n", commit.SHA[:7])
-				continue
+				return fmt.Errorf("failed to load existing examples: %w", err)
 			}
+		}
 
-			fullMessage, err := git.GetCommitMessage(repoRoot, commit.SHA)
+		// Fetch diffs and full messages, saving whatever was done if interrupted
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		perRepo := make([][]config.Example, 0, len(repoRoots))
+		// the last learned commit is tracked per repository, even for global examples
+		lastLearned := make(map[string]string, len(repoRoots))
+		interrupted := false
+		for _, repoRoot := range repoRoots {
+			repoID, err := storageKeyFor(repoRoot, false)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to get message for commit %s: %v\n", commit.SHA, err)
-				continue // Skip this commit if message fails
+				return err
 			}
+			learned, newestSHA, err := learnFromRepo(ctx, repoRoot, stored.LastLearned[repoID], filter)
+			if ctx.Err() != nil {
+				interrupted = true
+				perRepo = append(perRepo, learned)
+				break
+			}
+			if err != nil {
+				return err
+			}
+			perRepo = append(perRepo, learned)
+			if newestSHA != "" {
+				lastLearned[repoID] = newestSHA
+			}
+		}
+		learnedExamples := interleaveExamples(perRepo)
 
+		if interrupted {
+			stop() // a second interrupt terminates immediately
+			fmt.Fprintf(os.Stderr, "Interrupted, adding the %d examples extracted so far to the stored examples\n", len(learnedExamples))
+		}
+		if len(learnedExamples) == 0 {
+			fmt.Println("No examples extracted, nothing to save.")
+			return nil
+		}
 
-			// TODO: filter commits based on max length (tokens)
-			// Issue URL: https://github.com/Kabilan108/diffgpt/issues/1
-
-			learnedExamples = append(learnedExamples, config.Example{
-				Diff:    diff,
-				Message: fullMessage,
-			})
+		// even a partial run is scored and curated, only judging is skipped
+		if err := scoreExamples(ctx, storageKey, learnedExamples); err != nil {
+			return err
+		}
+		scored := len(learnedExamples)
+		learnedExamples = score.Curate(learnedExamples, learnKeep, learnMinScore)
+		if len(learnedExamples) < scored {
+			fmt.Printf("Kept the %d best of %d examples.\n", len(learnedExamples), scored)
+		}
+		if len(learnedExamples) == 0 {
+			fmt.Println("No examples scored high enough, nothing to save.")
+			return nil
 		}
 
 		// Store examples
-		cfg.Examples[storageKey] = learnedExamples
-		if err := config.SaveConfig(cfg); err != nil {
+		added, total := len(learnedExamples), len(learnedExamples)
+		err := config.UpdateExamples(storageKey, func(s *config.StoredExamples) error {
+			switch {
+			case learnUpdate:
+				added = s.AddLearned(learnedExamples, learnMax)
+			case interrupted:
+				// a partial run adds to the stored examples rather than
+				// replacing them
+				added = s.AddLearned(learnedExamples, 0)
+			default:
+				s.Examples = learnedExamples
+			}
+			total = len(s.Examples)
+			for repoID, sha := range lastLearned {
+				s.SetLastLearned(repoID, sha)
+			}
+			if !learnGlobal && learnSet == "" {
+				s.AddCheckout(repoRoots[0])
+			}
+			return nil
+		})
+		if err != nil {
 			return fmt.Errorf("failed to save learned examples: %w", err)
 		}
 
-		fmt.Printf("Successfully learned and saved %d examples for '%s'.\n", len(learnedExamples), storageKey)
+		if learnUpdate || interrupted {
+			fmt.Printf("Successfully learned %d new examples for '%s' (%d stored).\n", added, storageKey, total)
+			return nil
+		}
+		fmt.Printf("Successfully learned and saved %d examples for '%s'.\n", total, storageKey)
 		return nil
 	},
 }
 
+// scoreExamples rates examples with the heuristics in the score package and,
+// with --judge, averages that with the llm's rating. Once ctx is cancelled
+// the examples not yet judged keep their heuristic score.
+func scoreExamples(ctx context.Context, storageKey string, examples []config.Example) error {
+	scores := score.Heuristic(examples)
+	for i := range examples {
+		examples[i].Score = scores[i]
+	}
+	if !learnJudge {
+		return nil
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "Interrupted, skipping --judge and using the heuristic scores")
+		return nil
+	}
+
+	if o.apiKey == "" {
+		return fmt.Errorf("API Key not provided. Set DIFFGPT_API_KEY to use --judge")
+	}
+	models := parseModels(o.model)
+	if len(models) == 0 {
+		return fmt.Errorf("no model configured. Set DIFFGPT_MODEL to use --judge")
+	}
+	// judging falls back to the heuristic score, so only the primary model is used
+	model := models[0]
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if err := checkBudget(cfg); err != nil {
+		return err
+	}
+	client, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	client.OnUsage = recordUsage("judge", storageKey)
+	fmt.Printf("Asking %s to judge examples...\n", model)
+	for i, ex := range examples {
+		judged, err := llm.JudgeExample(ctx, client, model, ex.Diff, ex.Message)
+		if err != nil {
+			if ctx.Err() != nil {
+				fmt.Fprintf(os.Stderr, "Interrupted, using the heuristic scores of the %d examples not yet judged\n", len(examples)-i)
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "Warning: failed to judge example %d, using heuristic score: %v\n", i+1, err)
+			continue
+		}
+		examples[i].Score = math.Round((examples[i].Score+judged)/2*100) / 100
+	}
+	return nil
+}
+
+// learnFromRepo extracts examples from the commits of a single repository,
+// starting after lastSHA if it is set. It returns the examples along with the
+// newest commit they were learned from, which is empty if nothing was learned
+// or the extraction was interrupted (in which case the examples extracted so
+// far are still returned).
+func learnFromRepo(
+	ctx context.Context, repoRoot, lastSHA string, filter git.LogFilter,
+) ([]config.Example, string, error) {
+	// Fetch commit log
+	startRef := learnStart
+	count := learnCount
+	if learnUpdate {
+		head := startRef
+		if head == "" {
+			head = "HEAD"
+		}
+		if lastSHA != "" && !git.IsAncestor(repoRoot, lastSHA, head) {
+			fmt.Fprintf(os.Stderr, "Warning: last learned commit %s is no longer in the history of '%s', learning from scratch.\n",
+				lastSHA[:7], repoRoot)
+			lastSHA = ""
+		}
+		if lastSHA != "" {
+			startRef = lastSHA + ".." + head
+			// the last learned commit moves past every new commit, so all of
+			// them are learned, but only the newest --max can be kept unless
+			// they are curated by score
+			count = 0
+			if learnKeep == 0 && learnMinScore == 0 {
+				count = max(learnMax, 0)
+			}
+			fmt.Printf("Fetching new commits since %s from '%s'...\n", lastSHA[:7], repoRoot)
+		} else {
+			fmt.Printf("No previously learned commit recorded for '%s', learning from scratch.\n", repoRoot)
+		}
+	}
+	if !strings.Contains(startRef, "..") {
+		fmt.Printf("Fetching last %d commits from '%s'...\n", learnCount, repoRoot)
+	}
+	commits, err := git.GetCommitLog(repoRoot, startRef, count, filter)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch commit log: %w", err)
+	}
+	if len(commits) == 0 {
+		fmt.Println("No commits found matching criteria.")
+		return nil, "", nil
+	}
+
+	fmt.Println("Processing commits to extract diffs and messages...")
+	examples, err := extractExamples(ctx, repoRoot, commits, learnJobs)
+	if err != nil {
+		// some commits are missing, so the next --update has to look at them again
+		return examples, "", err
+	}
+	// commits are listed newest first
+	return examples, commits[0].SHA, nil
+}
+
+// interleaveExamples merges the examples learned from several repositories
+// round-robin, so a rolling maximum keeps a fair sample of each.
+func interleaveExamples(perRepo [][]config.Example) []config.Example {
+	var examples []config.Example
+	for i := 0; ; i++ {
+		more := false
+		for _, repoExamples := range perRepo {
+			if i < len(repoExamples) {
+				examples = append(examples, repoExamples[i])
+				more = true
+			}
+		}
+		if !more {
+			return examples
+		}
+	}
+}
+
+// extractExamples fetches the message and diff of every commit using a pool
+// of jobs workers, keeping the order of commits. If ctx is cancelled, the
+// examples extracted so far are returned along with the context's error.
+func extractExamples(ctx context.Context, repoRoot string, commits []git.CommitInfo, jobs int) ([]config.Example, error) {
+	results := make([]*config.Example, len(commits))
+	indices := make(chan int)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex // guards processed and progress output
+		processed int
+	)
+	for w := 0; w < max(jobs, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				commit := commits[i]
+				fullMessage, diff, err := git.GetCommitPatch(ctx, repoRoot, commit.SHA)
+
+				mu.Lock()
+				processed++
+				switch {
+				case ctx.Err() != nil:
+					// interrupted, git was killed mid-way
+				case err != nil:
+					fmt.Fprintf(os.Stderr, "Warning: failed to get diff for commit %s: %v\n", commit.SHA, err)
+				case diff == "":
+					// Skip empty diffs (e.g., merge commits without changes)
+					fmt.Printf("  [%d/%d] Skipping commit %s: empty diff\n", processed, len(commits), commit.SHA[:7])
+				default:
+					// TODO: filter commits based on max length (tokens)
+					// Issue URL: https://github.com/Kabilan108/diffgpt/issues/1
+					results[i] = &config.Example{
+						Diff:    diff,
+						Message: fullMessage,
+						SHA:     commit.SHA,
+					}
+					fmt.Printf("  [%d/%d] Processed commit %s (%s)\n", processed, len(commits), commit.SHA[:7], commit.Subject)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := range commits {
+		select {
+		case indices <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	examples := make([]config.Example, 0, len(commits))
+	for _, ex := range results {
+		if ex != nil {
+			examples = append(examples, *ex)
+		}
+	}
+	return examples, ctx.Err()
+}
+
+// storageKeyFor returns the key under which examples for repoRoot are stored.
+// Repositories are keyed by identity so all checkouts of a repository share examples.
+func storageKeyFor(repoRoot string, global bool) (string, error) {
+	if global {
+		return globalKey, nil
+	}
+	id, err := git.GetRepoIdentity(repoRoot)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine repository identity: %w", err)
+	}
+	return id, nil
+}
+
 func init() {
 	rootCmd.AddCommand(learnCmd)
 
 	learnCmd.Flags().BoolVarP(&learnGlobal, "global", "g", false, "Store examples globally instead of per-repository")
+	learnCmd.Flags().StringVar(&learnSet, "set", "", "Store examples in a named style set, learning from every given repository")
 	learnCmd.Flags().StringVarP(&learnStart, "start", "s", "", "Commit SHA or ref to start learning from (newest commit)")
 	learnCmd.Flags().BoolVarP(&learnClear, "clear", "c", false, "Clear existing examples for the target (repo or global)")
-	learnCmd.Flags().IntVarP(&learnCount, "count", "n", 10, "Number of recent commits to learn from")
+	learnCmd.Flags().IntVarP(&learnCount, "count", "n", 10, "Number of recent commits to learn from (per repository)")
+	learnCmd.Flags().BoolVarP(&learnUpdate, "update", "u", false, "Only learn from commits made since the last learn and add them to existing examples")
+	learnCmd.Flags().IntVar(&learnMax, "max", 50, "Maximum number of examples to keep when using --update, dropping the lowest scoring")
+	learnCmd.Flags().IntVar(&learnKeep, "keep", 0, "Only keep this many of the highest scoring examples (0 keeps all)")
+	learnCmd.Flags().Float64Var(&learnMinScore, "min-score", 0, "Drop examples scoring below this (0-1)")
+	learnCmd.Flags().BoolVar(&learnJudge, "judge", false, "Ask the llm to rate each example in addition to the heuristics")
+	learnCmd.Flags().IntVarP(&learnJobs, "jobs", "j", min(runtime.NumCPU(), 8), "Number of commits to process in parallel")
+
+	// commit filters
+	learnCmd.Flags().StringVar(&learnAuthor, "author", "", "Only learn from commits by authors matching this pattern")
+	learnCmd.Flags().StringVar(&learnSince, "since", "", "Only learn from commits more recent than this date")
+	learnCmd.Flags().StringVar(&learnUntil, "until", "", "Only learn from commits older than this date")
+	learnCmd.Flags().StringSliceVarP(&learnPaths, "path", "p", nil, "Only learn from commits touching these paths (repeatable)")
+	learnCmd.Flags().BoolVar(&learnNoMerges, "no-merges", false, "Skip merge commits")
+	learnCmd.Flags().IntVar(&learnMinSubjectLength, "min-subject-length", 0, "Skip commits whose subject is shorter than this")
+	learnCmd.Flags().StringVar(&learnExcludeMessage, "exclude-message", "", "Skip commits whose subject matches this regex (e.g. '^wip|^fixup!')")
+	learnCmd.Flags().BoolVar(&learnConventionalOnly, "conventional-only", false, "Only learn from commits following conventional commits")
 }
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kabilan108/diffgpt/internal/config"
+	"github.com/kabilan108/diffgpt/internal/convention"
+	"github.com/kabilan108/diffgpt/internal/git"
+	"github.com/kabilan108/diffgpt/internal/lint"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintInstallHook bool
+	lintForce       bool
+)
+
+const commitMsgHook = `#!/bin/sh
+# installed by diffgpt lint --install-hook
+exec %s lint "$1"
+`
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [msgfile|range]",
+	Short: "check commit messages against the repository's rules",
+	Long: `check commit messages against the rules generated messages follow: the commit
+convention and its types, subject length, the imperative mood, no trailing period, a
+blank line after the subject, the body's wrap width, allowed scopes and a required
+ticket reference.
+
+lints a message file (comment lines are ignored, as git does), the commits in a range
+such as main..HEAD, a single commit, or a message piped to stdin. without arguments
+the HEAD commit is linted.
+
+rules are read from the repository's .diffgpt/config.json:
+  "scopes": ["api", "cli"],
+  "ticket_pattern": "PROJ-[0-9]+",
+  "lint": {"max_subject_length": 50, "body_width": 72, "imperative": true, "require_ticket": true}
+
+--install-hook installs a commit-msg hook so every commit is linted.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repoRoot, err := git.GetRepoRoot("")
+		if err != nil {
+			repoRoot = ""
+		}
+		if lintInstallHook {
+			return installCommitMsgHook(repoRoot)
+		}
+
+		repoCfg, err := config.LoadRepoConfig(repoRoot)
+		if err != nil {
+			return err
+		}
+		conv, err := resolveConvention(repoCfg)
+		if err != nil {
+			return err
+		}
+		rules, err := lintRules(repoCfg, conv)
+		if err != nil {
+			return err
+		}
+		ticket, _, err := branchTicket(repoRoot, repoCfg)
+		if err != nil {
+			return err
+		}
+		rules = rules.WithTicket(ticket)
+
+		messages, err := lintTargets(repoRoot, args)
+		if err != nil {
+			return err
+		}
+		failed := 0
+		for _, m := range messages {
+			problems := lint.Lint(m.Message, rules)
+			if len(problems) == 0 {
+				continue
+			}
+			failed++
+			for _, p := range problems {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", m.SHA, p)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d commit messages have problems", failed, len(messages))
+		}
+		return nil
+	},
+}
+
+// lintTargets returns the messages to lint for args, labelled with the file
+// or commit they came from.
+func lintTargets(repoRoot string, args []string) ([]git.CommitMessage, error) {
+	if len(args) == 0 {
+		if !isTerminal(os.Stdin) {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read message from stdin: %w", err)
+			}
+			return []git.CommitMessage{{SHA: "stdin", Message: string(data)}}, nil
+		}
+		args = []string{"HEAD"}
+	}
+
+	if data, err := os.ReadFile(args[0]); err == nil {
+		return []git.CommitMessage{{SHA: args[0], Message: string(data)}}, nil
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read message file: %w", err)
+	}
+	messages, err := git.GetCommitMessages(repoRoot, args[0])
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		messages[i].SHA = messages[i].SHA[:min(len(messages[i].SHA), 7)]
+	}
+	return messages, nil
+}
+
+// lintRules returns the lint rules of the repository configured by repoCfg.
+func lintRules(repoCfg *config.RepoConfig, conv *convention.Convention) (lint.Rules, error) {
+	rules := lint.DefaultRules(conv)
+	rules.Scopes = repoCfg.Scopes
+	if l := repoCfg.Lint; l != nil {
+		if l.MaxSubjectLength != 0 {
+			rules.MaxSubjectLength = max(l.MaxSubjectLength, 0)
+		}
+		if l.BodyWidth != 0 {
+			rules.BodyWidth = max(l.BodyWidth, 0)
+		}
+		if l.Imperative != nil {
+			rules.Imperative = *l.Imperative
+		}
+		if l.RequireTicket && repoCfg.TicketPattern == "" {
+			return lint.Rules{}, fmt.Errorf("lint.require_ticket is set but ticket_pattern isn't")
+		}
+		rules.RequireTicket = l.RequireTicket
+	}
+	if repoCfg.TicketPattern != "" {
+		pattern, err := regexp.Compile(repoCfg.TicketPattern)
+		if err != nil {
+			return lint.Rules{}, fmt.Errorf("invalid ticket_pattern: %w", err)
+		}
+		rules.TicketPattern = pattern
+	}
+	return rules, nil
+}
+
+// installCommitMsgHook installs a commit-msg hook running `diffgpt lint`.
+func installCommitMsgHook(repoRoot string) error {
+	if repoRoot == "" {
+		return fmt.Errorf("not in a git repository")
+	}
+	hooksDir, err := git.GetHooksDir(repoRoot)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(hooksDir, "commit-msg")
+	if _, err := os.Stat(path); err == nil && !lintForce {
+		return fmt.Errorf("%s already exists, use --force to replace it", path)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the diffgpt executable: %w", err)
+	}
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(commitMsgHook, shellQuote(exe))), 0o755); err != nil {
+		return fmt.Errorf("failed to write hook: %w", err)
+	}
+	fmt.Printf("Installed commit-msg hook at %s\n", path)
+	return nil
+}
+
+// shellQuote quotes s as a single word for sh, in which nothing is expanded.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	lintCmd.Flags().BoolVar(&lintInstallHook, "install-hook", false, "install a commit-msg hook that lints every commit message")
+	lintCmd.Flags().BoolVar(&lintForce, "force", false, "replace an existing commit-msg hook")
+}
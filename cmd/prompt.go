@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kabilan108/diffgpt/internal/config"
+	"github.com/kabilan108/diffgpt/internal/git"
+	"github.com/kabilan108/diffgpt/internal/prompt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	promptStyle    string
+	promptDetailed bool
+)
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "inspect the prompts sent to the llm",
+	Long: `inspect the prompts diffgpt sends to the llm.
+
+prompts are go text/template files named system.tmpl and user.tmpl. each is looked
+up in the repository's .diffgpt/prompts directory, then in the prompts directory next
+to the config file, before falling back to the built-in template. learned examples
+are rendered with user.tmpl too. templates can use:
+  .Diff      the diff
+  .Files     paths of the changed files
+  .Stats     .Stats.Files, .Stats.Additions and .Stats.Deletions
+  .Branch    the current branch, empty if HEAD is detached
+  .Repo      the repository's identity, e.g. github.com/owner/repo
+  .Detailed  whether a detailed message was asked for
+  .Examples  the learned examples, each with .Diff, .Message, .SHA and .Score
+  .Convention  the commit convention, with .Name, .Summary and .Guidance`,
+}
+
+var promptShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "render the prompt for the staged changes",
+	Long: `render the exact prompt diffgpt would send for the staged changes, or for a diff
+piped to stdin, along with the template files it was rendered from.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repoRoot, err := git.GetRepoRoot("")
+		if err != nil {
+			repoRoot = ""
+		}
+		diff, err := readDiff(repoRoot)
+		if err != nil {
+			return err
+		}
+
+		_, loadErr := config.LoadConfig()
+		if loadErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to load config: %v\n", loadErr)
+		}
+		style := promptStyle
+		if style == "" {
+			style = o.style
+		}
+		repoCfg, err := config.LoadRepoConfig(repoRoot)
+		if err != nil {
+			return err
+		}
+		conv, err := resolveConvention(repoCfg)
+		if err != nil {
+			return err
+		}
+		p, templates, err := renderPrompt(repoRoot, repoIdentityOrWarn(repoRoot), diff, style, conv, promptDetailed, loadErr == nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("==> system (%s)\n%s\n", templates.Sources[prompt.SystemTemplate], strings.TrimRight(p.System, "\n"))
+		for i, ex := range p.Examples {
+			fmt.Printf("\n==> example %d: user\n%s\n", i+1, strings.TrimRight(ex.User, "\n"))
+			fmt.Printf("\n==> example %d: assistant\n%s\n", i+1, strings.TrimRight(ex.Assistant, "\n"))
+		}
+		fmt.Printf("\n==> user (%s)\n%s\n", templates.Sources[prompt.UserTemplate], strings.TrimRight(p.User, "\n"))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(promptCmd)
+	promptCmd.AddCommand(promptShowCmd)
+
+	promptShowCmd.Flags().StringVar(&promptStyle, "style", "", "named style set to use instead of the repository's examples")
+	promptShowCmd.Flags().BoolVarP(&promptDetailed, "detailed", "d", false, "render the prompt for a detailed commit message")
+}
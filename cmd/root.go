@@ -1,32 +1,53 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/kabilan108/diffgpt/internal/config"
+	"github.com/kabilan108/diffgpt/internal/convention"
 	"github.com/kabilan108/diffgpt/internal/git"
+	"github.com/kabilan108/diffgpt/internal/lint"
 	"github.com/kabilan108/diffgpt/internal/llm"
+	"github.com/kabilan108/diffgpt/internal/prompt"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 type Options struct {
-	baseUrl  string
-	apiKey   string
-	model    string
-	detailed bool
+	baseUrl    string
+	apiKey     string
+	model      string
+	detailed   bool
+	style      string
+	noStream   bool
+	timeout    time.Duration
+	retries    int
+	outputMode string
+	noCache    bool
+	regenerate bool
+	convention string
+	signOff    bool
+	coAuthors  []string
+	pair       bool
+	trailers   []string
 }
 
 var o = Options{}
 
+// maxLintRevisions is how many times a generated message that fails lint is
+// sent back to the model.
+const maxLintRevisions = 2
+
 var rootCmd = &cobra.Command{
-	Use:   "diffgpt",
+	Use:   "diffgpt [flags] [-- git commit options]",
 	Short: "Generate commit messages based on your diffs.",
 	Long: `generate commit messages from diffs
 
@@ -36,77 +57,133 @@ set the following environment variables to use a different provider.
   DIFFGPT_API_KEY:   api key for an llm provider
   DIFFGPT_BASE_URL:  base url for an openai-compatible api (e.g. https://api.openai.com/v1)
   DIFFGPT_MODEL:     model to use for generation (e.g. gpt-4o, anthropic/claude-3-haiku
+                     or a comma-separated list of models to fall back through, e.g. gpt-4o,gpt-4o-mini)
+  DIFFGPT_STYLE:     named style set to use (see 'diffgpt learn --set')
+  DIFFGPT_CONVENTION: commit convention to follow: conventional, angular, gitmoji,
+                     kernel or plain (default: the repository's .diffgpt/config.json, or conventional)
+  DIFFGPT_TIMEOUT:   timeout for each request to the llm provider (e.g. 30s)
+  DIFFGPT_RETRIES:   how many times to retry rate-limited or failed requests
+  DIFFGPT_OUTPUT_MODE: how to request structured output: auto (probe and remember what
+                     the provider supports), json_schema, json_object, tools or text
+
+arguments after -- are passed on to git commit, e.g. diffgpt -- -S --no-verify
 	`,
+	Args: commitArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if o.apiKey == "" {
 			return fmt.Errorf("API Key not provided. Set DIFFGPT_API_KEY or use --api-key flag")
 		}
-		client := llm.NewClient(o.apiKey, o.baseUrl)
-
-		var diffContent string
-		var err error
-		var repoRoot string
-
-		stat, _ := os.Stdin.Stat()
-		isPiped := (stat.Mode() & os.ModeCharDevice) == 0
-
-		// Attempt to determine repo root regardless of input mode
-		currentRepoRoot, repoErr := git.GetRepoRoot("")
-		if repoErr != nil {
-			fmt.Fprintf(os.Stderr, "Warning: could not determine repo root: %v\n", repoErr)
+		repoRoot, err := git.GetRepoRoot("")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not determine repo root: %v\n", err)
 			repoRoot = ""
-		} else {
-			repoRoot = currentRepoRoot
 		}
 
-		if isPiped {
-			diffBytes, readErr := io.ReadAll(os.Stdin)
-			if readErr != nil {
-				return fmt.Errorf("failed to read diff from stdin: %w", readErr)
-			}
-			diffContent = string(diffBytes)
-		} else {
-			diffContent, err = git.GetStagedDiff(repoRoot)
-			if err != nil {
-				return fmt.Errorf("failed to get staged diff: %w", err)
-			}
+		diffContent, err := readDiff(repoRoot)
+		if err != nil {
+			return err
 		}
-
 		if strings.TrimSpace(diffContent) == "" {
 			fmt.Fprintln(os.Stderr, "No changes to commit")
 			return nil
 		}
 
-		examples := []config.Example{}
 		cfg, loadErr := config.LoadConfig()
 		if loadErr != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to load config: %v\n", loadErr)
-		} else {
-			// always load global examples if they exist
-			if globalEx, ok := cfg.Examples["global"]; ok {
-				examples = append(examples, globalEx...)
-			}
-			// load repo-specific examples
-			if repoRoot != "" {
-				absRepoRoot, err := filepath.Abs(repoRoot)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Warning: failed to get absolute path for repo root: %v\n", err)
-				} else {
-					if repoEx, ok := cfg.Examples[absRepoRoot]; ok {
-						examples = append(examples, repoEx...)
-					}
-				}
-			}
+		}
+		repoID := repoIdentityOrWarn(repoRoot)
+		repoCfg, err := config.LoadRepoConfig(repoRoot)
+		if err != nil {
+			return err
+		}
+		conv, err := resolveConvention(repoCfg)
+		if err != nil {
+			return err
+		}
+		rules, err := lintRules(repoCfg, conv)
+		if err != nil {
+			return err
+		}
+		ticket, placement, err := branchTicket(repoRoot, repoCfg)
+		if err != nil {
+			return err
+		}
+		rules = rules.WithTicket(ticket)
+		// revisions can't fix what the model has no say in: without a ticket
+		// on the branch there is none to reference
+		checkRules := rules
+		if ticket == "" {
+			checkRules.RequireTicket = false
+		}
+		trailers, err := commitTrailers(repoRoot, repoCfg)
+		if err != nil {
+			return err
+		}
+		p, _, err := renderPrompt(repoRoot, repoID, diffContent, o.style, conv, o.detailed, loadErr == nil)
+		if err != nil {
+			return err
 		}
 
-		commitMsg, err := llm.GenerateCommitMessage(
-			context.Background(), client, o.model, diffContent, o.detailed, examples,
-		)
+		if err := checkBudget(cfg); err != nil {
+			return err
+		}
+		client, err := newClient(cfg)
 		if err != nil {
+			return err
+		}
+		record := recordUsage("generate", repoID)
+		requested := false
+		client.OnUsage = func(u llm.Usage) {
+			requested = true
+			record(u)
+		}
+
+		// cancel the in-flight request on ctrl-c
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		opts := llm.Options{
+			Models:          parseModels(o.model),
+			Detailed:        o.detailed,
+			Convention:      conv,
+			Scopes:          repoScopes(repoCfg, diffContent),
+			Ticket:          ticket,
+			TicketPlacement: placement,
+			OnFallback: func(failed, next string, err error) {
+				fmt.Fprintf(os.Stderr, "\nWarning: %s failed (%v), trying %s\n", failed, err, next)
+			},
+			Check: func(message string) error {
+				return lint.Error(lint.Lint(message, checkRules))
+			},
+			MaxRevisions: maxLintRevisions,
+			OnRevise: func(err error) {
+				fmt.Fprintf(os.Stderr, "\nMessage failed lint (%v), asking for a revision\n", err)
+			},
+		}
+		if !o.noStream && isTerminal(os.Stderr) {
+			opts.Stream = os.Stderr
+		}
+		result, err := llm.GenerateCommitMessage(ctx, client, p, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				fmt.Fprintln(os.Stderr, "\nGeneration cancelled")
+				return nil
+			}
 			return fmt.Errorf("failed to generate commit message: %w", err)
 		}
+		stop()
+		if len(opts.Models) > 1 {
+			fmt.Fprintf(os.Stderr, "Message generated by %s\n", result.Model)
+		}
+		if !requested && client.Cache != nil {
+			fmt.Fprintln(os.Stderr, "Using a cached message, run with --regenerate for a new one")
+		}
+		for _, problem := range lint.Lint(result.Message, rules) {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", problem)
+		}
 
-		if err := git.Commit(commitMsg, repoRoot); err != nil {
+		if err := git.Commit(result.Message, repoRoot, git.CommitOptions{Trailers: trailers, Args: args}); err != nil {
 			// Check for specific exit codes that indicate user actions rather than errors
 			// Git returns 1 when commit is aborted in editor
 			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
@@ -120,6 +197,229 @@ set the following environment variables to use a different provider.
 	},
 }
 
+// commitArgs accepts only arguments given after --, which are passed on to
+// git commit, so that a mistyped subcommand is still reported.
+func commitArgs(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 && cmd.ArgsLenAtDash() != 0 {
+		return fmt.Errorf("unknown command %q for %q", args[0], cmd.CommandPath())
+	}
+	return nil
+}
+
+// readDiff returns the diff to describe: stdin when it is piped, otherwise
+// the staged changes.
+func readDiff(repoRoot string) (string, error) {
+	if !isTerminal(os.Stdin) {
+		diffBytes, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read diff from stdin: %w", err)
+		}
+		return string(diffBytes), nil
+	}
+	diff, err := git.GetStagedChanges(repoRoot)
+	if err != nil {
+		return "", fmt.Errorf("failed to get staged diff: %w", err)
+	}
+	return diff, nil
+}
+
+// repoIdentityOrWarn returns the identity of the repository at repoRoot, or
+// an empty string outside a repository.
+func repoIdentityOrWarn(repoRoot string) string {
+	if repoRoot == "" {
+		return ""
+	}
+	id, err := storageKeyFor(repoRoot, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return ""
+	}
+	return id
+}
+
+// loadGenerationExamples returns the global examples followed by the named
+// style set's, or the repository's if no style is given.
+func loadGenerationExamples(repoID, style string) []config.Example {
+	// always load global examples if they exist
+	examples := loadExamplesOrWarn(globalKey)
+	// load a named style set in place of the repo-specific examples
+	if style != "" {
+		styleEx := loadExamplesOrWarn(styleSetKey(style))
+		if len(styleEx) == 0 {
+			fmt.Fprintf(os.Stderr, "Warning: style set '%s' has no examples\n", style)
+		}
+		return append(examples, styleEx...)
+	}
+	if repoID != "" {
+		examples = append(examples, loadExamplesOrWarn(repoID)...)
+	}
+	return examples
+}
+
+// resolveConvention returns the commit convention named by --convention, or
+// else by the repository's config, defaulting to conventional commits. The
+// repository's types replace the convention's.
+func resolveConvention(repoCfg *config.RepoConfig) (*convention.Convention, error) {
+	name := o.convention
+	if name == "" {
+		name = repoCfg.Convention
+	}
+	if name == "" {
+		name = convention.Default
+	}
+	conv, err := convention.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return conv.WithTypes(repoCfg.Types), nil
+}
+
+// repoScopes returns the scopes the repository allows for diff, or nil if
+// any scope will do.
+func repoScopes(repoCfg *config.RepoConfig, diff string) []string {
+	if repoCfg.InferScopes {
+		return convention.InferScopes(git.SummarizeDiff(diff).Files)
+	}
+	return repoCfg.Scopes
+}
+
+// branchTicket returns the ticket the current branch is for and where the
+// repository wants it referenced. The ticket is empty if the repository has
+// no ticket patterns or the branch doesn't match them.
+func branchTicket(repoRoot string, repoCfg *config.RepoConfig) (string, convention.Placement, error) {
+	placement, err := convention.ParsePlacement(repoCfg.TicketPlacement)
+	if err != nil {
+		return "", "", err
+	}
+	sources := repoCfg.TicketBranchPatterns
+	if len(sources) == 0 && repoCfg.TicketPattern != "" {
+		sources = []string{repoCfg.TicketPattern}
+	}
+	if len(sources) == 0 || repoRoot == "" {
+		return "", placement, nil
+	}
+
+	patterns := make([]*regexp.Regexp, len(sources))
+	for i, source := range sources {
+		if patterns[i], err = regexp.Compile(source); err != nil {
+			return "", "", fmt.Errorf("invalid ticket branch pattern: %w", err)
+		}
+	}
+	branch, err := git.GetCurrentBranch(repoRoot)
+	if err != nil {
+		return "", "", err
+	}
+	return convention.TicketFromBranch(branch, patterns), placement, nil
+}
+
+// renderPrompt renders the prompt for diff with the repository's templates,
+// including the learned examples if withExamples is set.
+func renderPrompt(
+	repoRoot, repoID, diff, style string, conv *convention.Convention, detailed, withExamples bool,
+) (prompt.Prompt, *prompt.Templates, error) {
+	templates, err := prompt.Load(repoRoot)
+	if err != nil {
+		return prompt.Prompt{}, nil, err
+	}
+
+	data := prompt.NewData(diff)
+	data.Repo = repoID
+	data.Detailed = detailed
+	data.Convention = conv
+	if repoRoot != "" {
+		if data.Branch, err = git.GetCurrentBranch(repoRoot); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	if withExamples {
+		data.Examples = loadGenerationExamples(repoID, style)
+	}
+
+	p, err := templates.Render(data)
+	if err != nil {
+		return prompt.Prompt{}, nil, err
+	}
+	return p, templates, nil
+}
+
+// newClient creates an llm client using the request timeout, retry, output
+// mode and cache flags. Probed output modes are remembered across runs, and
+// responses are cached within the limits set in cfg (which may be nil).
+func newClient(cfg *config.Config) (*llm.Client, error) {
+	mode, err := llm.ParseOutputMode(o.outputMode)
+	if err != nil {
+		return nil, err
+	}
+	client := llm.NewClient(o.apiKey, o.baseUrl)
+	client.Retry.Timeout = o.timeout
+	client.Retry.MaxAttempts = max(o.retries, 0) + 1
+	client.Mode = mode
+	client.Modes = configModeCache{}
+	client.Regenerate = o.regenerate
+
+	if !o.noCache {
+		cacheDir, err := config.GetCacheDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: responses won't be cached: %v\n", err)
+			return client, nil
+		}
+		client.Cache = llm.NewCache(filepath.Join(cacheDir, "responses"))
+		if cfg != nil && cfg.Cache != nil {
+			if cfg.Cache.TTLHours > 0 {
+				client.Cache.TTL = time.Duration(cfg.Cache.TTLHours * float64(time.Hour))
+			}
+			if cfg.Cache.MaxSizeMB > 0 {
+				client.Cache.MaxSize = int64(cfg.Cache.MaxSizeMB * (1 << 20))
+			}
+		}
+	}
+	return client, nil
+}
+
+// configModeCache stores probed output modes in the config directory.
+type configModeCache struct{}
+
+func (configModeCache) OutputMode(baseURL, model string) (llm.OutputMode, bool) {
+	mode, ok, err := config.LoadOutputMode(baseURL, model)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load output mode: %v\n", err)
+		return llm.ModeAuto, false
+	}
+	return llm.OutputMode(mode), ok
+}
+
+func (configModeCache) SetOutputMode(baseURL, model string, mode llm.OutputMode) {
+	if err := config.SaveOutputMode(baseURL, model, string(mode)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save output mode: %v\n", err)
+	}
+}
+
+// parseModels splits a comma-separated --model value into the models to try
+// in order.
+func parseModels(value string) []string {
+	var models []string
+	for _, model := range strings.Split(value, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && (stat.Mode()&os.ModeCharDevice) != 0
+}
+
+func loadExamplesOrWarn(key string) []config.Example {
+	examples, err := config.LoadExamples(key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load examples for '%s': %v\n", key, err)
+		return nil
+	}
+	return examples
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -132,13 +432,30 @@ func init() {
 	// diffgpt flags
 	rootCmd.Flags().StringVarP(&o.apiKey, "api-key", "k", "", "api key for llm provider")
 	rootCmd.Flags().StringVarP(&o.baseUrl, "base-url", "u", "https://api.openai.com/v1", "base url for llm provider")
-	rootCmd.Flags().StringVarP(&o.model, "model", "m", "google/gemini-2.0-flash-001", "llm to use for generation")
+	rootCmd.Flags().StringVarP(&o.model, "model", "m", "google/gemini-2.0-flash-001", "llm to use for generation, or a comma-separated list to fall back through")
 	rootCmd.Flags().BoolVarP(&o.detailed, "detailed", "d", false, "whether to generate a detailed commit message")
+	rootCmd.Flags().BoolVar(&o.noStream, "no-stream", false, "don't show the message while it is being generated")
+	rootCmd.Flags().StringVar(&o.style, "style", "", "named style set to use instead of the repository's examples")
+	rootCmd.Flags().BoolVarP(&o.signOff, "signoff", "s", false, "add a Signed-off-by trailer")
+	rootCmd.Flags().StringArrayVar(&o.coAuthors, "co-author", nil, "add a Co-authored-by trailer for a name from the repository's co_authors, or 'Name <email>'")
+	rootCmd.Flags().BoolVar(&o.pair, "pair", false, "credit the co-authors of your commits from the last 12 hours")
+	rootCmd.Flags().StringArrayVar(&o.trailers, "trailer", nil, "add a trailer, e.g. 'Reviewed-by: Name <email>'")
+	rootCmd.PersistentFlags().StringVar(&o.convention, "convention", "", "commit convention to follow: "+strings.Join(convention.Names(), ", ")+" (default: the repository's, or conventional)")
+	rootCmd.PersistentFlags().DurationVar(&o.timeout, "timeout", llm.DefaultRetryPolicy.Timeout, "timeout for each request to the llm provider (0 for none)")
+	rootCmd.PersistentFlags().IntVar(&o.retries, "retries", llm.DefaultRetryPolicy.MaxAttempts-1, "how many times to retry rate-limited or failed requests")
+	rootCmd.PersistentFlags().StringVar(&o.outputMode, "output-mode", "auto", "how to request structured output: auto, json_schema, json_object, tools or text")
+	rootCmd.PersistentFlags().BoolVar(&o.noCache, "no-cache", false, "don't read or write cached llm responses")
+	rootCmd.PersistentFlags().BoolVar(&o.regenerate, "regenerate", false, "ignore cached llm responses and ask for new ones")
 
 	// bind env vars to flags
 	viper.BindPFlag("api_key", rootCmd.Flags().Lookup("api-key"))
 	viper.BindPFlag("base_url", rootCmd.Flags().Lookup("base-url"))
 	viper.BindPFlag("model", rootCmd.Flags().Lookup("model"))
+	viper.BindPFlag("style", rootCmd.Flags().Lookup("style"))
+	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	viper.BindPFlag("retries", rootCmd.PersistentFlags().Lookup("retries"))
+	viper.BindPFlag("output_mode", rootCmd.PersistentFlags().Lookup("output-mode"))
+	viper.BindPFlag("convention", rootCmd.PersistentFlags().Lookup("convention"))
 }
 
 func initConfig() {
@@ -152,4 +469,9 @@ func initConfig() {
 	}
 	o.baseUrl = viper.GetString("base_url")
 	o.model = viper.GetString("model")
-}
\ No newline at end of file
+	o.style = viper.GetString("style")
+	o.timeout = viper.GetDuration("timeout")
+	o.retries = viper.GetInt("retries")
+	o.outputMode = viper.GetString("output_mode")
+	o.convention = viper.GetString("convention")
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kabilan108/diffgpt/internal/config"
+	"github.com/kabilan108/diffgpt/internal/llm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsSince string
+	statsBy    []string
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "show llm usage and cost",
+	Long: `show the requests diffgpt has made to the llm provider, with their tokens,
+latency and cost, grouped by day, repository and model.
+
+costs are computed from the price table in config.json, in USD per million tokens:
+  "prices": {"gpt-4o-mini": {"input": 0.15, "output": 0.6}}
+
+a monthly budget warns once it is spent, or refuses further requests with "block":
+  "budget": {"monthly_usd": 10, "block": true}`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		since, err := parseSince(statsSince, time.Now())
+		if err != nil {
+			return err
+		}
+		groups := map[string]func(r config.UsageRecord) string{
+			"day":   func(r config.UsageRecord) string { return r.Time.Local().Format(time.DateOnly) },
+			"repo":  func(r config.UsageRecord) string { return r.Repo },
+			"model": func(r config.UsageRecord) string { return r.Model },
+		}
+		for _, by := range statsBy {
+			if groups[by] == nil {
+				return fmt.Errorf("cannot group by '%s' (expected day, repo or model)", by)
+			}
+		}
+
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return err
+		}
+		records, err := config.LoadUsage(since)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			fmt.Printf("No usage recorded since %s.\n", since.Format(time.DateOnly))
+			return nil
+		}
+
+		fmt.Printf("Usage since %s\n", since.Format(time.DateOnly))
+		unpriced := false
+		for _, by := range statsBy {
+			fmt.Println()
+			unpriced = printUsageTable(cfg, strings.ToUpper(by), records, groups[by]) || unpriced
+		}
+		if unpriced {
+			fmt.Println("\n* includes models without a price in config.json")
+		}
+
+		if cfg.Budget != nil && cfg.Budget.MonthlyUSD > 0 {
+			spent, err := cfg.SpentThisMonth()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("\nBudget: $%.2f of $%.2f spent this month\n", spent, cfg.Budget.MonthlyUSD)
+		}
+		return nil
+	},
+}
+
+// usageTotals accumulates the usage of a group of requests.
+type usageTotals struct {
+	requests         int
+	promptTokens     int64
+	completionTokens int64
+	latency          time.Duration
+	cost             float64
+	unpriced         bool
+}
+
+func (t *usageTotals) add(cfg *config.Config, r config.UsageRecord) {
+	t.requests++
+	t.promptTokens += r.PromptTokens
+	t.completionTokens += r.CompletionTokens
+	t.latency += time.Duration(r.LatencyMs) * time.Millisecond
+	cost, ok := cfg.Cost(r)
+	t.cost += cost
+	t.unpriced = t.unpriced || !ok
+}
+
+// printUsageTable prints the totals of records grouped by key, reporting
+// whether any of them couldn't be priced.
+func printUsageTable(cfg *config.Config, title string, records []config.UsageRecord, key func(config.UsageRecord) string) bool {
+	totals := map[string]*usageTotals{}
+	var total usageTotals
+	for _, r := range records {
+		k := key(r)
+		if k == "" {
+			k = "-"
+		}
+		if totals[k] == nil {
+			totals[k] = &usageTotals{}
+		}
+		totals[k].add(cfg, r)
+		total.add(cfg, r)
+	}
+	keys := make([]string, 0, len(totals))
+	for k := range totals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%s\tREQUESTS\tINPUT TOKENS\tOUTPUT TOKENS\tAVG LATENCY\tCOST\t\n", title)
+	for _, k := range keys {
+		printUsageRow(w, k, totals[k])
+	}
+	printUsageRow(w, "TOTAL", &total)
+	w.Flush()
+	return total.unpriced
+}
+
+func printUsageRow(w *tabwriter.Writer, label string, t *usageTotals) {
+	avg := (t.latency / time.Duration(t.requests)).Round(100 * time.Millisecond)
+	cost := fmt.Sprintf("$%.4f", t.cost)
+	if t.unpriced && t.cost == 0 {
+		cost = "-"
+	} else if t.unpriced {
+		cost += "*"
+	}
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\t\n", label, t.requests, t.promptTokens, t.completionTokens, avg, cost)
+}
+
+// parseSince parses --since as a date (2006-01-02) or a number of days (30d).
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since '%s' (expected a date like 2006-01-02 or a number of days like 30d)", value)
+}
+
+// recordUsage returns a usage callback that appends every request made for
+// command in repo to the usage log.
+func recordUsage(command, repo string) func(llm.Usage) {
+	return func(u llm.Usage) {
+		err := config.AppendUsage(config.UsageRecord{
+			Time:             time.Now().UTC(),
+			Command:          command,
+			Repo:             repo,
+			Model:            u.Model,
+			PromptTokens:     u.PromptTokens,
+			CompletionTokens: u.CompletionTokens,
+			LatencyMs:        u.Latency.Milliseconds(),
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record usage: %v\n", err)
+		}
+	}
+}
+
+// checkBudget warns when this month's budget is spent, or returns an error if
+// the budget blocks further requests.
+func checkBudget(cfg *config.Config) error {
+	if cfg == nil || cfg.Budget == nil || cfg.Budget.MonthlyUSD <= 0 {
+		return nil
+	}
+	spent, err := cfg.SpentThisMonth()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to check budget: %v\n", err)
+		return nil
+	}
+	if spent < cfg.Budget.MonthlyUSD {
+		return nil
+	}
+	if cfg.Budget.Block {
+		return fmt.Errorf("monthly budget of $%.2f is spent ($%.2f); raise budget.monthly_usd in config.json to continue",
+			cfg.Budget.MonthlyUSD, spent)
+	}
+	fmt.Fprintf(os.Stderr, "Warning: monthly budget of $%.2f is spent ($%.2f)\n", cfg.Budget.MonthlyUSD, spent)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().StringVar(&statsSince, "since", "30d", "show usage since a date (2006-01-02) or for a number of days (30d)")
+	statsCmd.Flags().StringSliceVar(&statsBy, "by", []string{"day", "repo", "model"}, "group usage by day, repo and/or model")
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kabilan108/diffgpt/internal/config"
+	"github.com/kabilan108/diffgpt/internal/git"
+)
+
+// pairSessionWindow is how far back --pair looks for the co-authors of the
+// current pairing session.
+const pairSessionWindow = "12 hours ago"
+
+// commitTrailers returns the trailers to add to the commit: the repository's
+// and --trailer's, then co-authors, then the sign-off.
+func commitTrailers(repoRoot string, repoCfg *config.RepoConfig) ([]git.Trailer, error) {
+	var trailers []git.Trailer
+	for _, s := range append(append([]string{}, repoCfg.Trailers...), o.trailers...) {
+		t, err := git.ParseTrailer(s)
+		if err != nil {
+			return nil, err
+		}
+		trailers = append(trailers, t)
+	}
+
+	for _, name := range o.coAuthors {
+		coAuthor, ok := repoCfg.CoAuthors[name]
+		if !ok {
+			if !strings.Contains(name, "<") {
+				return nil, fmt.Errorf("unknown co-author '%s' (add it to co_authors in %s or use 'Name <email>')",
+					name, config.RepoConfigPath)
+			}
+			coAuthor = name
+		}
+		trailers = append(trailers, git.Trailer{Key: "Co-authored-by", Value: coAuthor})
+	}
+	if o.pair {
+		coAuthors, err := git.GetRecentCoAuthors(repoRoot, pairSessionWindow)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else if len(coAuthors) == 0 {
+			fmt.Fprintln(os.Stderr, "Warning: no co-authors on your recent commits")
+		} else {
+			fmt.Fprintf(os.Stderr, "Crediting co-authors of your recent commits: %s\n", strings.Join(coAuthors, ", "))
+		}
+		for _, coAuthor := range coAuthors {
+			trailers = append(trailers, git.Trailer{Key: "Co-authored-by", Value: coAuthor})
+		}
+	}
+
+	if o.signOff || repoCfg.SignOff {
+		ident, err := git.GetCommitterIdent(repoRoot)
+		if err != nil {
+			return nil, err
+		}
+		trailers = append(trailers, git.Trailer{Key: "Signed-off-by", Value: ident})
+	}
+	return trailers, nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const bundleVersion = 1
+
+// Bundle is a portable set of examples that can be shared between machines.
+// It deliberately carries no storage key, the importer decides where the
+// examples are stored.
+type Bundle struct {
+	Version  int       `json:"version"`
+	Source   string    `json:"source,omitempty"`
+	Examples []Example `json:"examples"`
+}
+
+func NewBundle(source string, examples []Example) *Bundle {
+	return &Bundle{Version: bundleVersion, Source: source, Examples: examples}
+}
+
+func WriteBundle(w io.Writer, b *Bundle) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b); err != nil {
+		return fmt.Errorf("failed to encode bundle: %w", err)
+	}
+	return nil
+}
+
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	var b Bundle
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle: %w", err)
+	}
+	if b.Version == 0 || b.Version > bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.Version)
+	}
+	return &b, nil
+}
+
+// MergeExamples appends the examples in incoming that are not already present
+// in existing and returns the merged slice along with the number added.
+func MergeExamples(existing, incoming []Example) ([]Example, int) {
+	seen := make(map[Example]bool, len(existing))
+	for _, ex := range existing {
+		seen[ex] = true
+	}
+	merged := existing
+	added := 0
+	for _, ex := range incoming {
+		if seen[ex] {
+			continue
+		}
+		seen[ex] = true
+		merged = append(merged, ex)
+		added++
+	}
+	return merged, added
+}
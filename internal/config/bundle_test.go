@@ -0,0 +1,59 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	examples := []Example{
+		{Diff: "diff1", Message: "msg1"},
+		{Diff: "diff2", Message: "msg2"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, NewBundle("/path/to/repo", examples)); err != nil {
+		t.Fatalf("WriteBundle() failed: %v", err)
+	}
+
+	b, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatalf("ReadBundle() failed: %v", err)
+	}
+	if !reflect.DeepEqual(b.Examples, examples) {
+		t.Errorf("Expected examples %+v, got %+v", examples, b.Examples)
+	}
+	if b.Source != "/path/to/repo" {
+		t.Errorf("Expected source '/path/to/repo', got '%s'", b.Source)
+	}
+}
+
+func TestReadBundle_UnsupportedVersion(t *testing.T) {
+	_, err := ReadBundle(strings.NewReader(`{"version": 99, "examples": []}`))
+	if err == nil {
+		t.Fatal("ReadBundle() succeeded with unsupported version, expected error")
+	}
+}
+
+func TestMergeExamples(t *testing.T) {
+	existing := []Example{{Diff: "diff1", Message: "msg1"}}
+	incoming := []Example{
+		{Diff: "diff1", Message: "msg1"},
+		{Diff: "diff2", Message: "msg2"},
+		{Diff: "diff2", Message: "msg2"},
+	}
+
+	merged, added := MergeExamples(existing, incoming)
+	if added != 1 {
+		t.Errorf("Expected 1 example added, got %d", added)
+	}
+	expected := []Example{
+		{Diff: "diff1", Message: "msg1"},
+		{Diff: "diff2", Message: "msg2"},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Expected merged %+v, got %+v", expected, merged)
+	}
+}
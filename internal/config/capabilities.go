@@ -0,0 +1,122 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The way a provider accepts structured output is probed on first use and
+// remembered per base URL and model, so later runs go straight to a request
+// format that works. Entries expire so providers that gain support are
+// eventually probed again.
+const (
+	capabilitiesFileName = "capabilities.json"
+	capabilitiesVersion  = 1
+	capabilityTTL        = 30 * 24 * time.Hour
+)
+
+// Capability records the output mode that worked for a model.
+type Capability struct {
+	OutputMode string    `json:"output_mode"`
+	ProbedAt   time.Time `json:"probed_at"`
+}
+
+type capabilities struct {
+	Version int                   `json:"version"`
+	Models  map[string]Capability `json:"models"`
+}
+
+var timeNow = time.Now
+
+func capabilitiesPath() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configPath), capabilitiesFileName), nil
+}
+
+func capabilityKey(baseURL, model string) string {
+	return strings.TrimRight(baseURL, "/") + " " + model
+}
+
+// LoadOutputMode returns the output mode recorded for model at baseURL, if it
+// was probed recently enough to still be trusted.
+func LoadOutputMode(baseURL, model string) (string, bool, error) {
+	path, err := capabilitiesPath()
+	if err != nil {
+		return "", false, err
+	}
+	unlock, err := lockFile(path, false)
+	if err != nil {
+		return "", false, err
+	}
+	defer unlock()
+
+	c, err := readCapabilities(path)
+	if err != nil {
+		return "", false, err
+	}
+	entry, ok := c.Models[capabilityKey(baseURL, model)]
+	if !ok || timeNow().Sub(entry.ProbedAt) > capabilityTTL {
+		return "", false, nil
+	}
+	return entry.OutputMode, true, nil
+}
+
+// SaveOutputMode records the output mode that worked for model at baseURL.
+func SaveOutputMode(baseURL, model, mode string) error {
+	if err := ensureConfigDir(); err != nil {
+		return err
+	}
+	path, err := capabilitiesPath()
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(path, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	c, err := readCapabilities(path)
+	if err != nil {
+		return err
+	}
+	c.Models[capabilityKey(baseURL, model)] = Capability{OutputMode: mode, ProbedAt: timeNow().UTC()}
+
+	c.Version = capabilitiesVersion
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal capabilities to JSON: %w", err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+func readCapabilities(path string) (*capabilities, error) {
+	c := &capabilities{Version: capabilitiesVersion}
+
+	data, err := osReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read capabilities file %s: %w", path, err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("failed to parse capabilities file %s: %w", path, err)
+		}
+		if c.Version > capabilitiesVersion {
+			return nil, fmt.Errorf(
+				"capabilities file %s has version %d, newer than supported version %d; please upgrade diffgpt",
+				path, c.Version, capabilitiesVersion,
+			)
+		}
+	}
+	if c.Models == nil {
+		c.Models = map[string]Capability{}
+	}
+	return c, nil
+}
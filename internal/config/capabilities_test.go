@@ -0,0 +1,64 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutputMode_SaveLoad(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	if _, ok, err := LoadOutputMode("https://api.example.com/v1", "model-a"); err != nil || ok {
+		t.Fatalf("LoadOutputMode() = %v, %v before anything was saved", ok, err)
+	}
+
+	if err := SaveOutputMode("https://api.example.com/v1/", "model-a", "json_object"); err != nil {
+		t.Fatalf("SaveOutputMode() failed: %v", err)
+	}
+	if err := SaveOutputMode("https://api.example.com/v1", "model-b", "tools"); err != nil {
+		t.Fatalf("SaveOutputMode() failed: %v", err)
+	}
+
+	tests := []struct {
+		baseURL, model string
+		want           string
+		ok             bool
+	}{
+		// trailing slashes don't matter
+		{"https://api.example.com/v1", "model-a", "json_object", true},
+		{"https://api.example.com/v1", "model-b", "tools", true},
+		{"https://other.example.com/v1", "model-a", "", false},
+	}
+	for _, tt := range tests {
+		mode, ok, err := LoadOutputMode(tt.baseURL, tt.model)
+		if err != nil {
+			t.Fatalf("LoadOutputMode(%s, %s) failed: %v", tt.baseURL, tt.model, err)
+		}
+		if mode != tt.want || ok != tt.ok {
+			t.Errorf("LoadOutputMode(%s, %s) = %q, %v; want %q, %v", tt.baseURL, tt.model, mode, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestOutputMode_Expires(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	if err := SaveOutputMode("https://api.example.com/v1", "model-a", "text"); err != nil {
+		t.Fatalf("SaveOutputMode() failed: %v", err)
+	}
+
+	now = now.Add(capabilityTTL - time.Hour)
+	if _, ok, _ := LoadOutputMode("https://api.example.com/v1", "model-a"); !ok {
+		t.Error("Expected the output mode to still be trusted before it expires")
+	}
+	now = now.Add(2 * time.Hour)
+	if _, ok, _ := LoadOutputMode("https://api.example.com/v1", "model-a"); ok {
+		t.Error("Expected the output mode to expire")
+	}
+}
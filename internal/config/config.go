@@ -5,15 +5,38 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/kabilan108/diffgpt/internal/git"
 )
 
 type Example struct {
 	Diff    string `json:"diff"`
 	Message string `json:"message"`
+	// SHA of the commit the example was learned from, empty for hand-added examples.
+	SHA string `json:"sha,omitempty"`
+	// Score rates how good an example the pair is, from 0 to 1 (0 if unscored).
+	Score float64 `json:"score,omitempty"`
 }
 
 type Config struct {
-	Examples map[string][]Example `json:"examples"`
+	Version int `json:"version"`
+
+	// Prices maps model names to what they cost, for `diffgpt stats` and the budget.
+	Prices map[string]Price `json:"prices,omitempty"`
+	Budget *Budget          `json:"budget,omitempty"`
+	// Cache limits the cache of llm responses, see CacheConfig.
+	Cache *CacheConfig `json:"cache,omitempty"`
+
+	// Examples and Checkouts are only read to migrate configs written before
+	// examples moved to their own store, see migrateExamplesToStore.
+	Examples  map[string][]Example `json:"examples,omitempty"`
+	Checkouts map[string][]string  `json:"checkouts,omitempty"`
+}
+
+// CacheConfig overrides the default limits of the response cache.
+type CacheConfig struct {
+	TTLHours  float64 `json:"ttl_hours,omitempty"`
+	MaxSizeMB float64 `json:"max_size_mb,omitempty"`
 }
 
 const (
@@ -23,11 +46,14 @@ const (
 
 var (
 	osUserConfigDir = os.UserConfigDir
+	osUserCacheDir  = os.UserCacheDir
 	osMkdirAll      = os.MkdirAll
 	osWriteFile     = os.WriteFile
 	osReadFile      = os.ReadFile
 	osRename        = os.Rename
 	osRemove        = os.Remove // Need this for SaveConfig cleanup check
+
+	repoIdentity = git.GetRepoIdentity
 )
 
 func GetConfigPath() (string, error) {
@@ -39,6 +65,16 @@ func GetConfigPath() (string, error) {
 	return filepath.Join(appDir, configFileName), nil
 }
 
+// GetCacheDir returns the directory for data that is safe to delete, such as
+// cached llm responses.
+func GetCacheDir() (string, error) {
+	cacheDir, err := osUserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache dir: %w", err)
+	}
+	return filepath.Join(cacheDir, appConfigDir), nil
+}
+
 func ensureConfigDir() error {
 	configPath, err := GetConfigPath()
 	if err != nil {
@@ -58,7 +94,7 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
-	cfg := &Config{Examples: make(map[string][]Example)}
+	cfg := &Config{Version: currentConfigVersion}
 
 	data, err := osReadFile(configPath)
 	if err != nil {
@@ -69,12 +105,22 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file %s: %w", configPath, err)
 	}
 
+	// files written before versioning have no version field
+	cfg.Version = 0
 	if err := json.Unmarshal(data, cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file %s: %w", configPath, err)
 	}
 
-	if cfg.Examples == nil {
-		cfg.Examples = make(map[string][]Example)
+	if cfg.Version > currentConfigVersion {
+		return nil, fmt.Errorf(
+			"config file %s has version %d, but this diffgpt only supports up to version %d; please upgrade diffgpt",
+			configPath, cfg.Version, currentConfigVersion,
+		)
+	}
+	if cfg.Version < currentConfigVersion {
+		if err := migrateConfig(cfg, configPath, data); err != nil {
+			return nil, err
+		}
 	}
 
 	return cfg, nil
@@ -90,24 +136,31 @@ func SaveConfig(cfg *Config) error {
 		return err
 	}
 
+	cfg.Version = currentConfigVersion
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config to JSON: %w", err)
 	}
 
-	tempFile := configPath + ".tmp"
-	err = osWriteFile(tempFile, data, 0o600)
+	return writeFileAtomic(configPath, data)
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over path so
+// readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tempFile := path + ".tmp"
+	err := osWriteFile(tempFile, data, 0o600)
 	if err != nil {
-		return fmt.Errorf("failed to write temporary config file %s: %w", tempFile, err)
+		return fmt.Errorf("failed to write temporary file %s: %w", tempFile, err)
 	}
 
-	if err := osRename(tempFile, configPath); err != nil {
+	if err := osRename(tempFile, path); err != nil {
 		// Handle cleanup failure explicitly rather than ignoring it
 		if removeErr := osRemove(tempFile); removeErr != nil {
 			return fmt.Errorf("failed to rename temp file to %s: %w (and failed to remove temp file: %v)",
-				configPath, err, removeErr)
+				path, err, removeErr)
 		}
-		return fmt.Errorf("failed to rename temporary config file to %s: %w", configPath, err)
+		return fmt.Errorf("failed to rename temporary file to %s: %w", path, err)
 	}
 
 	return nil
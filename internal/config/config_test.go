@@ -2,6 +2,8 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -19,6 +21,7 @@ func setupTestConfig(t *testing.T) (string, func()) {
 	ogReadFile := os.ReadFile
 	ogRename := os.Rename
 	ogRemove := os.Remove // Add remove for cleanup simulation
+	ogRepoIdentity := repoIdentity
 
 	tempDir := t.TempDir()
 	testConfigDir := filepath.Join(tempDir, appConfigDir)
@@ -52,11 +55,11 @@ func setupTestConfig(t *testing.T) (string, func()) {
 		return ogWriteFile(name, data, perm) // Allow writing other files if needed
 	}
 	osReadFile = func(name string) ([]byte, error) {
-		if name == testConfigPath {
+		if name == testConfigPath || strings.HasPrefix(name, testConfigDir+string(filepath.Separator)) {
 			return ogReadFile(name)
 		}
 		t.Logf("Intercepted ReadFile for path: %s", name)
-		// Simulate file not found for paths other than the target config
+		// Simulate file not found for paths outside the test config dir
 		return nil, os.ErrNotExist
 	}
 	osRename = func(oldpath, newpath string) error {
@@ -74,6 +77,11 @@ func setupTestConfig(t *testing.T) (string, func()) {
 		return ogRemove(name)
 	}
 
+	// Treat every path as its own identity so tests don't depend on git
+	repoIdentity = func(path string) (string, error) {
+		return path, nil
+	}
+
 	// Teardown function to restore og functions
 	cleanup := func() {
 		osUserConfigDir = ogUserConfigDir
@@ -82,11 +90,29 @@ func setupTestConfig(t *testing.T) (string, func()) {
 		osReadFile = ogReadFile
 		osRename = ogRename
 		osRemove = ogRemove
+		repoIdentity = ogRepoIdentity
 	}
 
 	return testConfigPath, cleanup
 }
 
+// writeConfigFile writes cfg to path as-is, bypassing SaveConfig so that
+// configs from older versions can be simulated.
+func writeConfigFile(t *testing.T, path string, cfg *Config) {
+	t.Helper()
+
+	if err := ensureConfigDir(); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+}
+
 func TestGetConfigPath(t *testing.T) {
 	_, cleanup := setupTestConfig(t)
 	defer cleanup()
@@ -120,11 +146,8 @@ func TestLoadConfig_NotFound(t *testing.T) {
 	if cfg == nil {
 		t.Fatal("LoadConfig() returned nil config when file doesn't exist")
 	}
-	if cfg.Examples == nil {
-		t.Fatal("LoadConfig() returned config with nil Examples map")
-	}
 	if len(cfg.Examples) != 0 {
-		t.Errorf("Expected empty Examples map, got %d entries", len(cfg.Examples))
+		t.Errorf("Expected no legacy examples, got %d entries", len(cfg.Examples))
 	}
 }
 
@@ -133,17 +156,7 @@ func TestSaveLoadConfig(t *testing.T) {
 	defer cleanup()
 
 	// --- Save ---
-	expectedCfg := &Config{
-		Examples: map[string][]Example{
-			"global": {
-				{Diff: "diff1", Message: "msg1"},
-			},
-			"/path/to/repo": {
-				{Diff: "diff2", Message: "msg2"},
-				{Diff: "diff3", Message: "msg3"},
-			},
-		},
-	}
+	expectedCfg := &Config{}
 
 	err := SaveConfig(expectedCfg)
 	if err != nil {
@@ -166,6 +179,50 @@ func TestSaveLoadConfig(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_MigratesExamplesToStore(t *testing.T) {
+	configPath, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	legacyCfg := &Config{
+		Examples: map[string][]Example{
+			"global": {
+				{Diff: "diff1", Message: "msg1"},
+			},
+			"/path/to/repo": {
+				{Diff: "diff2", Message: "msg2"},
+				{Diff: "diff3", Message: "msg3"},
+			},
+		},
+	}
+	writeConfigFile(t, configPath, legacyCfg)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() failed: %v", err)
+	}
+	if len(cfg.Examples) != 0 {
+		t.Errorf("Expected legacy examples to be moved out of the config, got %d entries", len(cfg.Examples))
+	}
+
+	for key, expected := range legacyCfg.Examples {
+		examples, err := LoadExamples(key)
+		if err != nil {
+			t.Fatalf("LoadExamples(%q) failed: %v", key, err)
+		}
+		if !reflect.DeepEqual(examples, expected) {
+			t.Errorf("Expected examples %+v for '%s', got %+v", expected, key, examples)
+		}
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	if strings.Contains(string(data), "diff1") {
+		t.Errorf("Expected migrated config file to no longer contain examples, got %s", data)
+	}
+}
+
 func TestLoadConfig_InvalidJson(t *testing.T) {
 	configPath, cleanup := setupTestConfig(t)
 	defer cleanup()
@@ -184,3 +241,137 @@ func TestLoadConfig_InvalidJson(t *testing.T) {
 	}
 	t.Logf("Got expected error for invalid JSON: %v", err) // Log error for info
 }
+
+func TestLoadConfig_MigratesPathKeys(t *testing.T) {
+	configPath, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	repoIdentity = func(path string) (string, error) {
+		if path == "/path/to/clone" || path == "/path/to/worktree" {
+			return "github.com/owner/repo", nil
+		}
+		return path, nil
+	}
+
+	legacyCfg := &Config{
+		Examples: map[string][]Example{
+			"global":            {{Diff: "diff1", Message: "msg1"}},
+			"/path/to/clone":    {{Diff: "diff2", Message: "msg2"}},
+			"/path/to/worktree": {{Diff: "diff2", Message: "msg2"}, {Diff: "diff3", Message: "msg3"}},
+			"/path/to/gone":     {{Diff: "diff4", Message: "msg4"}},
+		},
+	}
+	writeConfigFile(t, configPath, legacyCfg)
+
+	if _, err := LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig() failed: %v", err)
+	}
+
+	keys, err := ListExampleKeys()
+	if err != nil {
+		t.Fatalf("ListExampleKeys() failed: %v", err)
+	}
+	expectedKeys := []string{"/path/to/gone", "github.com/owner/repo", "global"}
+	if !reflect.DeepEqual(keys, expectedKeys) {
+		t.Errorf("Expected keys %v after migration, got %v", expectedKeys, keys)
+	}
+
+	s, err := LoadStoredExamples("github.com/owner/repo")
+	if err != nil {
+		t.Fatalf("LoadStoredExamples() failed: %v", err)
+	}
+	if len(s.Examples) != 2 {
+		t.Errorf("Expected 2 deduplicated examples under remote identity, got %d", len(s.Examples))
+	}
+	if len(s.Checkouts) != 2 {
+		t.Errorf("Expected 2 recorded checkouts, got %d", len(s.Checkouts))
+	}
+}
+
+func TestLoadConfig_NewerVersion(t *testing.T) {
+	configPath, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	writeConfigFile(t, configPath, &Config{Version: currentConfigVersion + 1})
+
+	_, err := LoadConfig()
+	if err == nil {
+		t.Fatal("LoadConfig() succeeded with a newer config version, expected error")
+	}
+	if !strings.Contains(err.Error(), "upgrade") {
+		t.Errorf("Expected error to ask for an upgrade, got: %v", err)
+	}
+}
+
+func TestLoadConfig_MigrationBackupAndVersion(t *testing.T) {
+	configPath, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	legacyCfg := &Config{
+		Examples: map[string][]Example{
+			"global": {{Diff: "diff1", Message: "msg1"}},
+		},
+	}
+	writeConfigFile(t, configPath, legacyCfg)
+	original, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() failed: %v", err)
+	}
+	if cfg.Version != currentConfigVersion {
+		t.Errorf("Expected migrated config version %d, got %d", currentConfigVersion, cfg.Version)
+	}
+
+	backup, err := os.ReadFile(fmt.Sprintf("%s.v0.bak", configPath))
+	if err != nil {
+		t.Fatalf("Expected a backup of the original config: %v", err)
+	}
+	if string(backup) != string(original) {
+		t.Errorf("Expected backup to match the original config.\nExpected: %s\nGot:      %s", original, backup)
+	}
+
+	saved := &Config{}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	if err := json.Unmarshal(data, saved); err != nil {
+		t.Fatalf("Failed to parse migrated config: %v", err)
+	}
+	if saved.Version != currentConfigVersion {
+		t.Errorf("Expected saved config version %d, got %d", currentConfigVersion, saved.Version)
+	}
+}
+
+func TestMigrations_StepByStep(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	ogMigrations := migrations
+	defer func() { migrations = ogMigrations }()
+
+	var steps []int
+	migrations = nil
+	for i := 0; i < currentConfigVersion; i++ {
+		migrations = append(migrations, func(cfg *Config) error {
+			steps = append(steps, cfg.Version)
+			return nil
+		})
+	}
+
+	cfg := &Config{Version: 1}
+	if err := migrateConfig(cfg, filepath.Join(t.TempDir(), "config.json"), []byte("{}")); err != nil {
+		t.Fatalf("migrateConfig() failed: %v", err)
+	}
+	expected := []int{}
+	for v := 1; v < currentConfigVersion; v++ {
+		expected = append(expected, v)
+	}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Errorf("Expected migrations to run for versions %v, got %v", expected, steps)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package config
+
+// lockFile is a no-op on platforms without flock; concurrent writers are
+// still protected from torn files by the atomic rename in writeFileAtomic.
+func lockFile(path string, exclusive bool) (func(), error) {
+	return func() {}, nil
+}
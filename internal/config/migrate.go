@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// migrations upgrade a config one version at a time: migrations[i] upgrades a
+// config from version i to version i+1. Append new migrations to the end and
+// never reorder or remove existing ones.
+var migrations = []func(cfg *Config) error{
+	// 0 -> 1: examples keyed by absolute path are re-keyed by repository identity
+	func(cfg *Config) error {
+		migratePathKeys(cfg)
+		return nil
+	},
+	// 1 -> 2: examples move out of config.json into the examples store
+	migrateExamplesToStore,
+}
+
+var currentConfigVersion = len(migrations)
+
+// migrateConfig upgrades cfg to the current version, keeping a copy of the
+// original file next to it, and saves the result.
+func migrateConfig(cfg *Config, configPath string, original []byte) error {
+	backupPath := fmt.Sprintf("%s.v%d.bak", configPath, cfg.Version)
+	if err := osWriteFile(backupPath, original, 0o600); err != nil {
+		return fmt.Errorf("failed to back up config file to %s: %w", backupPath, err)
+	}
+
+	for cfg.Version < currentConfigVersion {
+		if err := migrations[cfg.Version](cfg); err != nil {
+			return fmt.Errorf("failed to migrate config from version %d to %d (backup at %s): %w",
+				cfg.Version, cfg.Version+1, backupPath, err)
+		}
+		cfg.Version++
+	}
+
+	if err := SaveConfig(cfg); err != nil {
+		return fmt.Errorf("failed to save migrated config (backup at %s): %w", backupPath, err)
+	}
+	return nil
+}
+
+// migratePathKeys re-keys examples stored under an absolute repository path
+// (the format used before repository identities) to the identity of that
+// repository.
+func migratePathKeys(cfg *Config) {
+	for key, examples := range cfg.Examples {
+		if !filepath.IsAbs(key) {
+			continue
+		}
+		id, err := repoIdentity(key)
+		if err != nil || id == key {
+			continue
+		}
+		cfg.Examples[id], _ = MergeExamples(cfg.Examples[id], examples)
+		delete(cfg.Examples, key)
+		cfg.addCheckout(id, key)
+	}
+}
+
+func (c *Config) addCheckout(key, path string) {
+	if c.Checkouts == nil {
+		c.Checkouts = make(map[string][]string)
+	}
+	for _, p := range c.Checkouts[key] {
+		if p == path {
+			return
+		}
+	}
+	c.Checkouts[key] = append(c.Checkouts[key], path)
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RepoConfigPath is where a repository keeps the settings shared by everyone
+// committing to it, relative to the repository root.
+const RepoConfigPath = ".diffgpt/config.json"
+
+// RepoConfig holds a repository's shared settings.
+type RepoConfig struct {
+	// Convention names the commit convention messages should follow.
+	Convention string `json:"convention,omitempty"`
+	// Types replaces the convention's commit types, e.g. to add "deps".
+	Types []string `json:"types,omitempty"`
+	// Scopes limits the scopes messages can use. With InferScopes, the
+	// scopes are instead taken from the directories a change touches.
+	Scopes      []string `json:"scopes,omitempty"`
+	InferScopes bool     `json:"infer_scopes,omitempty"`
+	// TicketPattern is a regular expression matching the repository's ticket
+	// references, e.g. "PROJ-[0-9]+".
+	TicketPattern string `json:"ticket_pattern,omitempty"`
+	// TicketBranchPatterns find the ticket a branch is for, e.g.
+	// "^feature/(PROJ-[0-9]+)", defaulting to TicketPattern.
+	TicketBranchPatterns []string `json:"ticket_branch_patterns,omitempty"`
+	// TicketPlacement puts the branch's ticket in a "Refs:" footer or in
+	// the subject, "footer" (the default) or "subject".
+	TicketPlacement string      `json:"ticket_placement,omitempty"`
+	Lint            *LintConfig `json:"lint,omitempty"`
+	// SignOff adds a Signed-off-by trailer to every commit.
+	SignOff bool `json:"signoff,omitempty"`
+	// CoAuthors is the team roster for --co-author, mapping short names to
+	// "Name <email>".
+	CoAuthors map[string]string `json:"co_authors,omitempty"`
+	// Trailers are added to every commit, e.g. "Change-type: feature".
+	Trailers []string `json:"trailers,omitempty"`
+}
+
+// LintConfig overrides the default rules of `diffgpt lint`. Lengths of -1
+// disable the check.
+type LintConfig struct {
+	MaxSubjectLength int   `json:"max_subject_length,omitempty"`
+	BodyWidth        int   `json:"body_width,omitempty"`
+	Imperative       *bool `json:"imperative,omitempty"`
+	// RequireTicket requires messages to match the ticket pattern.
+	RequireTicket bool `json:"require_ticket,omitempty"`
+}
+
+// LoadRepoConfig reads the settings of the repository at repoRoot. A
+// repository without a config file gets the zero RepoConfig.
+func LoadRepoConfig(repoRoot string) (*RepoConfig, error) {
+	rc := &RepoConfig{}
+	if repoRoot == "" {
+		return rc, nil
+	}
+	path := filepath.Join(repoRoot, RepoConfigPath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return rc, nil
+		}
+		return nil, fmt.Errorf("failed to read repo config %s: %w", path, err)
+	}
+
+	// the file is written by hand, so misspelled keys are reported rather
+	// than silently ignored
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(rc); err != nil {
+		return nil, fmt.Errorf("failed to parse repo config %s: %w", path, err)
+	}
+	return rc, nil
+}
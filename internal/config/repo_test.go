@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeRepoConfig(t *testing.T, repoRoot, text string) {
+	t.Helper()
+	path := filepath.Join(repoRoot, RepoConfigPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadRepoConfig(t *testing.T) {
+	repoRoot := t.TempDir()
+
+	rc, err := LoadRepoConfig(repoRoot)
+	if err != nil {
+		t.Fatalf("LoadRepoConfig() without a file failed: %v", err)
+	}
+	if rc.Convention != "" {
+		t.Errorf("Expected empty repo config, got %+v", rc)
+	}
+
+	writeRepoConfig(t, repoRoot, `{"convention": "angular", "types": ["feat", "fix", "deps"], "scopes": ["api"], "infer_scopes": true}`)
+	rc, err = LoadRepoConfig(repoRoot)
+	if err != nil {
+		t.Fatalf("LoadRepoConfig() failed: %v", err)
+	}
+	if rc.Convention != "angular" || len(rc.Types) != 3 || len(rc.Scopes) != 1 || !rc.InferScopes {
+		t.Errorf("Unexpected repo config: %+v", rc)
+	}
+}
+
+func TestLoadRepoConfig_UnknownField(t *testing.T) {
+	repoRoot := t.TempDir()
+	writeRepoConfig(t, repoRoot, `{"conventoin": "kernel"}`)
+
+	_, err := LoadRepoConfig(repoRoot)
+	if err == nil || !strings.Contains(err.Error(), "conventoin") {
+		t.Errorf("Expected an error naming the unknown field, got %v", err)
+	}
+}
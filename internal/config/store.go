@@ -0,0 +1,271 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Examples are kept out of config.json in a directory holding one file per
+// storage key, so generation only reads the examples it needs and concurrent
+// writers to different keys never touch the same file.
+const (
+	examplesDirName       = "examples"
+	examplesFileExt       = ".json"
+	storedExamplesVersion = 1
+)
+
+// StoredExamples is the on-disk representation of the examples for a single
+// storage key (a repository identity or "global").
+type StoredExamples struct {
+	Version  int       `json:"version"`
+	Key      string    `json:"key"`
+	Examples []Example `json:"examples"`
+	// Checkouts records the local paths at which the repository was seen.
+	Checkouts []string `json:"checkouts,omitempty"`
+	// LastLearned maps repository identities to the newest commit learned from them.
+	LastLearned map[string]string `json:"last_learned,omitempty"`
+}
+
+// AddLearned prepends newly learned examples (newest first), dropping any
+// whose commit is already stored, and trims the set to at most max examples
+// by discarding the lowest scoring, the oldest first among equal scores. A
+// max of zero or less means no limit. It returns the number of examples
+// added.
+func (s *StoredExamples) AddLearned(learned []Example, max int) int {
+	seen := make(map[string]bool, len(s.Examples))
+	for _, ex := range s.Examples {
+		if ex.SHA != "" {
+			seen[ex.SHA] = true
+		}
+	}
+
+	added := make([]Example, 0, len(learned))
+	for _, ex := range learned {
+		if ex.SHA != "" {
+			if seen[ex.SHA] {
+				continue
+			}
+			seen[ex.SHA] = true
+		}
+		added = append(added, ex)
+	}
+
+	s.Examples = append(added, s.Examples...)
+	if max > 0 && len(s.Examples) > max {
+		order := make([]int, len(s.Examples))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return s.Examples[order[a]].Score > s.Examples[order[b]].Score
+		})
+		// keep the best, in their stored order
+		kept := order[:max]
+		sort.Ints(kept)
+		examples := make([]Example, 0, max)
+		for _, i := range kept {
+			examples = append(examples, s.Examples[i])
+		}
+		s.Examples = examples
+	}
+	return len(added)
+}
+
+// SetLastLearned records sha as the newest commit learned from repo.
+func (s *StoredExamples) SetLastLearned(repo, sha string) {
+	if s.LastLearned == nil {
+		s.LastLearned = make(map[string]string)
+	}
+	s.LastLearned[repo] = sha
+}
+
+// AddCheckout records path as a known checkout of the repository.
+func (s *StoredExamples) AddCheckout(path string) {
+	for _, p := range s.Checkouts {
+		if p == path {
+			return
+		}
+	}
+	s.Checkouts = append(s.Checkouts, path)
+}
+
+func getExamplesDir() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configPath), examplesDirName), nil
+}
+
+// examplesPath returns the file that holds the examples for key. Keys are
+// escaped so identities like "github.com/owner/repo" map to a single file.
+func examplesPath(key string) (string, error) {
+	dir, err := getExamplesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, url.QueryEscape(key)+examplesFileExt), nil
+}
+
+// LoadStoredExamples reads the examples stored for key. A missing file yields
+// an empty set rather than an error.
+func LoadStoredExamples(key string) (*StoredExamples, error) {
+	path, err := examplesPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := lockFile(path, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return readStoredExamples(path, key)
+}
+
+// LoadExamples returns the examples stored for key.
+func LoadExamples(key string) ([]Example, error) {
+	s, err := LoadStoredExamples(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.Examples, nil
+}
+
+// UpdateExamples atomically modifies the examples stored for key while
+// holding an exclusive lock, so concurrent updates never lose data. If fn
+// leaves no examples behind, the file for key is removed.
+func UpdateExamples(key string, fn func(s *StoredExamples) error) error {
+	dir, err := getExamplesDir()
+	if err != nil {
+		return err
+	}
+	if err := osMkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create examples directory %s: %w", dir, err)
+	}
+	path, err := examplesPath(key)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(path, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := readStoredExamples(path, key)
+	if err != nil {
+		return err
+	}
+	if err := fn(s); err != nil {
+		return err
+	}
+
+	if len(s.Examples) == 0 {
+		if err := osRemove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove examples file %s: %w", path, err)
+		}
+		return nil
+	}
+
+	s.Version = storedExamplesVersion
+	s.Key = key
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal examples to JSON: %w", err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+// DeleteExamples removes all examples stored for key and reports whether
+// there were any.
+func DeleteExamples(key string) (bool, error) {
+	existed := false
+	err := UpdateExamples(key, func(s *StoredExamples) error {
+		existed = len(s.Examples) > 0
+		s.Examples = nil
+		return nil
+	})
+	return existed, err
+}
+
+// ListExampleKeys returns the sorted storage keys that have examples.
+func ListExampleKeys() ([]string, error) {
+	dir, err := getExamplesDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read examples directory %s: %w", dir, err)
+	}
+
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, examplesFileExt) {
+			continue
+		}
+		key, err := url.QueryUnescape(strings.TrimSuffix(name, examplesFileExt))
+		if err != nil {
+			continue // not one of ours
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func readStoredExamples(path, key string) (*StoredExamples, error) {
+	s := &StoredExamples{Version: storedExamplesVersion, Key: key}
+
+	data, err := osReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read examples file %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse examples file %s: %w", path, err)
+	}
+	if s.Version > storedExamplesVersion {
+		return nil, fmt.Errorf(
+			"examples file %s has version %d, newer than supported version %d; please upgrade diffgpt",
+			path, s.Version, storedExamplesVersion,
+		)
+	}
+	return s, nil
+}
+
+// migrateExamplesToStore moves examples still kept in config.json (the format
+// used before the examples store) into the store, merging with anything that
+// is already there.
+func migrateExamplesToStore(cfg *Config) error {
+	for key, examples := range cfg.Examples {
+		err := UpdateExamples(key, func(s *StoredExamples) error {
+			s.Examples, _ = MergeExamples(s.Examples, examples)
+			for _, path := range cfg.Checkouts[key] {
+				s.AddCheckout(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate examples for '%s': %w", key, err)
+		}
+	}
+	cfg.Examples = nil
+	cfg.Checkouts = nil
+	return nil
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestLoadExamples_NotFound(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	examples, err := LoadExamples("github.com/owner/repo")
+	if err != nil {
+		t.Fatalf("LoadExamples() failed when no examples are stored: %v", err)
+	}
+	if len(examples) != 0 {
+		t.Errorf("Expected no examples, got %d", len(examples))
+	}
+}
+
+func TestUpdateExamples(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	key := "github.com/owner/repo"
+	expected := []Example{{Diff: "diff1", Message: "msg1"}}
+
+	err := UpdateExamples(key, func(s *StoredExamples) error {
+		s.Examples = expected
+		s.AddCheckout("/path/to/repo")
+		s.AddCheckout("/path/to/repo")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateExamples() failed: %v", err)
+	}
+
+	s, err := LoadStoredExamples(key)
+	if err != nil {
+		t.Fatalf("LoadStoredExamples() failed: %v", err)
+	}
+	if !reflect.DeepEqual(s.Examples, expected) {
+		t.Errorf("Expected examples %+v, got %+v", expected, s.Examples)
+	}
+	if !reflect.DeepEqual(s.Checkouts, []string{"/path/to/repo"}) {
+		t.Errorf("Expected a single checkout, got %v", s.Checkouts)
+	}
+
+	keys, err := ListExampleKeys()
+	if err != nil {
+		t.Fatalf("ListExampleKeys() failed: %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{key}) {
+		t.Errorf("Expected keys [%s], got %v", key, keys)
+	}
+
+	existed, err := DeleteExamples(key)
+	if err != nil {
+		t.Fatalf("DeleteExamples() failed: %v", err)
+	}
+	if !existed {
+		t.Error("Expected DeleteExamples() to report existing examples")
+	}
+	keys, err = ListExampleKeys()
+	if err != nil {
+		t.Fatalf("ListExampleKeys() failed: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("Expected no keys after delete, got %v", keys)
+	}
+}
+
+func TestUpdateExamples_Concurrent(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := UpdateExamples("global", func(s *StoredExamples) error {
+				s.Examples = append(s.Examples, Example{Diff: "diff", Message: string(rune('a' + i))})
+				return nil
+			})
+			if err != nil {
+				t.Errorf("UpdateExamples() failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	examples, err := LoadExamples("global")
+	if err != nil {
+		t.Fatalf("LoadExamples() failed: %v", err)
+	}
+	if len(examples) != writers {
+		t.Errorf("Expected %d examples after concurrent updates, got %d", writers, len(examples))
+	}
+}
+
+func TestLoadExamples_NewerVersion(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	if err := UpdateExamples("global", func(s *StoredExamples) error {
+		s.Examples = []Example{{Diff: "diff1", Message: "msg1"}}
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateExamples() failed: %v", err)
+	}
+	path, err := examplesPath("global")
+	if err != nil {
+		t.Fatalf("examplesPath() failed: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"version": 99, "examples": []}`), 0o600); err != nil {
+		t.Fatalf("Failed to write examples file: %v", err)
+	}
+
+	if _, err := LoadExamples("global"); err == nil {
+		t.Fatal("LoadExamples() succeeded with a newer version, expected error")
+	}
+}
+
+func TestAddLearned(t *testing.T) {
+	s := &StoredExamples{
+		Examples: []Example{
+			{Diff: "diff2", Message: "msg2", SHA: "sha2"},
+			{Diff: "manual", Message: "manual"},
+			{Diff: "diff1", Message: "msg1", SHA: "sha1"},
+		},
+	}
+
+	added := s.AddLearned([]Example{
+		{Diff: "diff4", Message: "msg4", SHA: "sha4"},
+		{Diff: "diff3", Message: "msg3", SHA: "sha3"},
+		{Diff: "diff2", Message: "msg2", SHA: "sha2"},
+	}, 4)
+
+	if added != 2 {
+		t.Errorf("Expected 2 examples added, got %d", added)
+	}
+	expected := []Example{
+		{Diff: "diff4", Message: "msg4", SHA: "sha4"},
+		{Diff: "diff3", Message: "msg3", SHA: "sha3"},
+		{Diff: "diff2", Message: "msg2", SHA: "sha2"},
+		{Diff: "manual", Message: "manual"},
+	}
+	if !reflect.DeepEqual(s.Examples, expected) {
+		t.Errorf("Expected examples %+v, got %+v", expected, s.Examples)
+	}
+}
+
+func TestAddLearned_TrimsLowestScores(t *testing.T) {
+	s := &StoredExamples{
+		Examples: []Example{
+			{Message: "msg2", SHA: "sha2", Score: 0.9},
+			{Message: "msg1", SHA: "sha1", Score: 0.3},
+		},
+	}
+
+	s.AddLearned([]Example{
+		{Message: "msg4", SHA: "sha4", Score: 0.3},
+		{Message: "msg3", SHA: "sha3", Score: 0.7},
+	}, 3)
+
+	// the oldest of the two lowest scoring examples goes
+	expected := []Example{
+		{Message: "msg4", SHA: "sha4", Score: 0.3},
+		{Message: "msg3", SHA: "sha3", Score: 0.7},
+		{Message: "msg2", SHA: "sha2", Score: 0.9},
+	}
+	if !reflect.DeepEqual(s.Examples, expected) {
+		t.Errorf("Expected examples %+v, got %+v", expected, s.Examples)
+	}
+}
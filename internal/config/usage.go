@@ -0,0 +1,149 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Every request to the llm provider is appended to usage.jsonl, one JSON
+// record per line, so costs can be reported with `diffgpt stats` and checked
+// against the monthly budget.
+const usageFileName = "usage.jsonl"
+
+// UsageRecord describes a single request to the llm provider.
+type UsageRecord struct {
+	Time time.Time `json:"time"`
+	// Command is what the request was made for, e.g. "generate" or "judge".
+	Command          string `json:"command"`
+	Repo             string `json:"repo,omitempty"`
+	Model            string `json:"model"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	LatencyMs        int64  `json:"latency_ms"`
+}
+
+// Price is what a model costs in USD per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Budget limits what diffgpt may spend in a calendar month.
+type Budget struct {
+	MonthlyUSD float64 `json:"monthly_usd"`
+	// Block refuses further requests once the budget is spent instead of warning.
+	Block bool `json:"block,omitempty"`
+}
+
+func usagePath() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configPath), usageFileName), nil
+}
+
+// AppendUsage adds r to the usage log.
+func AppendUsage(r UsageRecord) error {
+	if err := ensureConfigDir(); err != nil {
+		return err
+	}
+	path, err := usagePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage record: %w", err)
+	}
+
+	unlock, err := lockFile(path, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open usage log %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage log %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadUsage returns the usage records made at or after since, oldest first.
+// Lines that can't be parsed (e.g. after a crash mid-write) are skipped.
+func LoadUsage(since time.Time) ([]UsageRecord, error) {
+	path, err := usagePath()
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := lockFile(path, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open usage log %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var records []UsageRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if !r.Time.Before(since) {
+			records = append(records, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage log %s: %w", path, err)
+	}
+	return records, nil
+}
+
+// Cost prices r with the configured price table, reporting false if the
+// model has no price.
+func (c *Config) Cost(r UsageRecord) (float64, bool) {
+	price, ok := c.Prices[r.Model]
+	if !ok {
+		return 0, false
+	}
+	return (float64(r.PromptTokens)*price.Input + float64(r.CompletionTokens)*price.Output) / 1e6, true
+}
+
+// MonthStart returns the start of the calendar month containing t, which is
+// when the monthly budget resets.
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// SpentThisMonth returns the priced cost of the requests made this month.
+func (c *Config) SpentThisMonth() (float64, error) {
+	records, err := LoadUsage(MonthStart(timeNow()))
+	if err != nil {
+		return 0, err
+	}
+	var total float64
+	for _, r := range records {
+		cost, _ := c.Cost(r)
+		total += cost
+	}
+	return total, nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestUsage_AppendLoad(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	records, err := LoadUsage(time.Time{})
+	if err != nil || len(records) != 0 {
+		t.Fatalf("LoadUsage() = %v, %v before anything was logged", records, err)
+	}
+
+	day := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	for i, model := range []string{"model-a", "model-b", "model-a"} {
+		err := AppendUsage(UsageRecord{
+			Time: day.AddDate(0, 0, i), Command: "generate", Repo: "github.com/owner/repo", Model: model,
+			PromptTokens: 1000, CompletionTokens: 100, LatencyMs: 800,
+		})
+		if err != nil {
+			t.Fatalf("AppendUsage() failed: %v", err)
+		}
+	}
+
+	records, err = LoadUsage(day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("LoadUsage() failed: %v", err)
+	}
+	if len(records) != 2 || records[0].Model != "model-b" || records[1].Model != "model-a" {
+		t.Errorf("Expected the last two records, oldest first, got %+v", records)
+	}
+}
+
+func TestUsage_SkipsCorruptLines(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	if err := AppendUsage(UsageRecord{Time: time.Now(), Model: "model-a"}); err != nil {
+		t.Fatalf("AppendUsage() failed: %v", err)
+	}
+	path, _ := usagePath()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time": "2025-`)
+	f.Close()
+
+	records, err := LoadUsage(time.Time{})
+	if err != nil {
+		t.Fatalf("LoadUsage() failed: %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("Expected 1 record, got %d", len(records))
+	}
+}
+
+func TestConfig_Cost(t *testing.T) {
+	cfg := &Config{Prices: map[string]Price{"model-a": {Input: 2.5, Output: 10}}}
+
+	cost, ok := cfg.Cost(UsageRecord{Model: "model-a", PromptTokens: 2000, CompletionTokens: 500})
+	if !ok || math.Abs(cost-0.01) > 1e-9 {
+		t.Errorf("Cost() = %v, %v; want 0.01, true", cost, ok)
+	}
+	if _, ok := cfg.Cost(UsageRecord{Model: "model-b", PromptTokens: 2000}); ok {
+		t.Error("Expected no cost for a model without a price")
+	}
+}
+
+func TestConfig_SpentThisMonth(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	cfg := &Config{Prices: map[string]Price{"model-a": {Input: 1, Output: 1}}}
+	for _, r := range []UsageRecord{
+		{Time: now.AddDate(0, -1, 0), Model: "model-a", PromptTokens: 1e6}, // last month
+		{Time: now.AddDate(0, 0, -10), Model: "model-a", PromptTokens: 1e6, CompletionTokens: 1e6},
+		{Time: now, Model: "unpriced", PromptTokens: 1e6},
+	} {
+		if err := AppendUsage(r); err != nil {
+			t.Fatalf("AppendUsage() failed: %v", err)
+		}
+	}
+
+	spent, err := cfg.SpentThisMonth()
+	if err != nil {
+		t.Fatalf("SpentThisMonth() failed: %v", err)
+	}
+	if spent != 2 {
+		t.Errorf("Expected $2 spent this month, got %v", spent)
+	}
+}
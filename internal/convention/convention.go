@@ -0,0 +1,301 @@
+// Package convention describes the commit message conventions diffgpt can
+// follow: the guidance given to the llm, the structured fields a message is
+// made of and a validator for the result.
+package convention
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Fields lists the structured parts a convention builds its subject from.
+type Fields struct {
+	Type     bool // e.g. "feat" or ":bug:"
+	Scope    bool // e.g. "(parser)", or the subsystem in "net: fix leak"
+	Breaking bool // whether breaking changes are marked in the subject
+}
+
+// Convention is a style of commit message.
+type Convention struct {
+	Name    string
+	Summary string
+	// Guidance tells the llm how to write messages in this convention.
+	Guidance string
+	Fields   Fields
+	// TypeHint and ScopeHint describe the type and scope fields to the llm
+	// when the convention gives them a special meaning.
+	TypeHint  string
+	ScopeHint string
+	// Types are the allowed types, for conventions with a type field.
+	Types []string
+	// validate checks the subject line of a message.
+	validate func(c *Convention, subject string) error
+	// header formats the subject line of a message.
+	header func(m Message) string
+	// parse splits a subject line into its parts.
+	parse func(subject string) (Message, bool)
+}
+
+// TypeError reports a type the convention doesn't allow.
+type TypeError struct {
+	Type  string
+	Types []string
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("type '%s' isn't one of %s", e.Type, strings.Join(e.Types, ", "))
+}
+
+// Validate checks that message follows the convention.
+func (c *Convention) Validate(message string) error {
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return fmt.Errorf("subject is empty")
+	}
+	return c.validate(c, subject)
+}
+
+// Parse splits the subject line of message into the type, scope and summary
+// the convention uses, reporting whether the subject has the convention's form.
+func (c *Convention) Parse(message string) (Message, bool) {
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return c.parse(strings.TrimSpace(subject))
+}
+
+// WithTypes returns a copy of c that only allows types. Conventions without
+// a type field are returned unchanged.
+func (c *Convention) WithTypes(types []string) *Convention {
+	if len(types) == 0 || !c.Fields.Type {
+		return c
+	}
+	copied := *c
+	copied.Types = types
+	return &copied
+}
+
+// Default is the convention used unless another one is configured.
+const Default = "conventional"
+
+var registry = map[string]*Convention{}
+
+func register(c *Convention) {
+	registry[c.Name] = c
+}
+
+// Get returns the convention called name.
+func Get(name string) (*Convention, error) {
+	if c, ok := registry[name]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown commit convention '%s' (expected one of %s)", name, strings.Join(Names(), ", "))
+}
+
+// Names returns the names of all conventions, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	conventionalTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+	angularTypes      = []string{"build", "ci", "docs", "feat", "fix", "perf", "refactor", "test"}
+
+	conventionalPattern = regexp.MustCompile(`^([a-zA-Z]+)(\([^()]+\))?(!)?: (\S.*)$`)
+	angularPattern      = regexp.MustCompile(`^([a-z]+)(\([a-z0-9._/-]+\))?: (\S.*)$`)
+	gitmojiPattern      = regexp.MustCompile(`^(:[a-z0-9_+-]+:) (\S.*)$`)
+	kernelPattern       = regexp.MustCompile(`^[\w./+-]+:( [\w./+-]+:)* (\S.*)$`)
+)
+
+func init() {
+	register(&Convention{
+		Name:    "conventional",
+		Summary: `Conventional Commits, e.g. "feat(auth): add login"`,
+		Guidance: `Follow the Conventional Commits standard: "type(scope): summary", where type is one of ` +
+			strings.Join(conventionalTypes, ", ") + `, the scope is optional and a "!" after the type or ` +
+			`scope marks a breaking change (e.g., "feat: add user login functionality").`,
+		Fields: Fields{Type: true, Scope: true, Breaking: true},
+		Types:  conventionalTypes,
+		validate: func(c *Convention, subject string) error {
+			m := conventionalPattern.FindStringSubmatch(subject)
+			if m == nil {
+				return fmt.Errorf(`subject "%s" isn't of the form "type(scope): summary"`, subject)
+			}
+			return checkType(m[1], c.Types)
+		},
+		header: func(m Message) string {
+			header := m.Type + parenthesize(m.Scope)
+			if m.Breaking {
+				header += "!"
+			}
+			return header + ": " + lowerFirst(m.Subject)
+		},
+		parse: parseTyped,
+	})
+
+	register(&Convention{
+		Name:    "angular",
+		Summary: `Angular commit guidelines, e.g. "fix(router): handle empty paths"`,
+		Guidance: `Follow the Angular commit message guidelines: "type(scope): summary", where type is one of ` +
+			strings.Join(angularTypes, ", ") + `, the scope names the affected package and is optional, ` +
+			`and the summary is in the imperative mood, lowercase, without a trailing period. ` +
+			`Breaking changes are described in a "BREAKING CHANGE:" footer rather than in the subject.`,
+		Fields: Fields{Type: true, Scope: true},
+		Types:  angularTypes,
+		validate: func(c *Convention, subject string) error {
+			m := angularPattern.FindStringSubmatch(subject)
+			if m == nil {
+				return fmt.Errorf(`subject "%s" isn't of the form "type(scope): summary" with a lowercase type and scope`, subject)
+			}
+			if err := checkType(m[1], c.Types); err != nil {
+				return err
+			}
+			summary := m[3]
+			if r, _ := utf8.DecodeRuneInString(summary); unicode.IsUpper(r) {
+				return fmt.Errorf(`summary "%s" should start with a lowercase letter`, summary)
+			}
+			if strings.HasSuffix(summary, ".") {
+				return fmt.Errorf(`summary "%s" shouldn't end with a period`, summary)
+			}
+			return nil
+		},
+		header: func(m Message) string {
+			return m.Type + parenthesize(strings.ToLower(m.Scope)) + ": " + lowerFirst(m.Subject)
+		},
+		parse: parseTyped,
+	})
+
+	register(&Convention{
+		Name:    "gitmoji",
+		Summary: `gitmoji, e.g. ":bug: fix crash on empty input"`,
+		Guidance: `Follow the gitmoji convention: start the subject with the gitmoji shortcode that best ` +
+			`describes the change, followed by a short summary (e.g., ":sparkles: add user login", ` +
+			`":bug: fix crash on empty input", ":recycle: simplify parser", ":memo: document flags").`,
+		Fields:   Fields{Type: true},
+		TypeHint: `The gitmoji shortcode that best describes the change, e.g. ":sparkles:" or ":bug:".`,
+		validate: func(c *Convention, subject string) error {
+			if gitmojiPattern.MatchString(subject) {
+				return nil
+			}
+			// the emoji itself is accepted in place of its shortcode
+			if emoji, summary, _ := strings.Cut(subject, " "); isEmoji(emoji) && strings.TrimSpace(summary) != "" {
+				return nil
+			}
+			return fmt.Errorf(`subject "%s" doesn't start with a gitmoji such as ":bug:"`, subject)
+		},
+		header: func(m Message) string {
+			emoji := m.Type
+			if emoji != "" && gitmojiPattern.MatchString(":"+strings.Trim(emoji, ":")+": x") {
+				emoji = ":" + strings.Trim(emoji, ":") + ":"
+			}
+			return strings.TrimSpace(emoji + " " + lowerFirst(m.Subject))
+		},
+		parse: func(subject string) (Message, bool) {
+			emoji, summary, ok := strings.Cut(subject, " ")
+			if !ok || (!gitmojiPattern.MatchString(subject) && !isEmoji(emoji)) {
+				return Message{Subject: subject}, false
+			}
+			return Message{Type: emoji, Subject: strings.TrimSpace(summary)}, true
+		},
+	})
+
+	register(&Convention{
+		Name:    "kernel",
+		Summary: `Linux kernel style, e.g. "net: ipv4: fix refcount leak"`,
+		Guidance: `Follow the Linux kernel style: "subsystem: summary", where subsystem names the part of the ` +
+			`code that changed (nested subsystems are separated by ": ", e.g., "net: ipv4: fix refcount leak") ` +
+			`and the summary is in the imperative mood without a trailing period.`,
+		Fields:    Fields{Scope: true},
+		ScopeHint: `The subsystem the change belongs to, e.g. "net" or "drm/i915", with nested subsystems separated by ": ".`,
+		validate: func(c *Convention, subject string) error {
+			m := kernelPattern.FindStringSubmatch(subject)
+			if m == nil {
+				return fmt.Errorf(`subject "%s" isn't of the form "subsystem: summary"`, subject)
+			}
+			if strings.HasSuffix(m[2], ".") {
+				return fmt.Errorf(`summary "%s" shouldn't end with a period`, m[2])
+			}
+			return nil
+		},
+		header: func(m Message) string {
+			if m.Scope == "" {
+				return lowerFirst(m.Subject)
+			}
+			return m.Scope + ": " + lowerFirst(m.Subject)
+		},
+		parse: func(subject string) (Message, bool) {
+			m := kernelPattern.FindStringSubmatch(subject)
+			if m == nil {
+				return Message{Subject: subject}, false
+			}
+			return Message{Scope: subject[:len(subject)-len(m[2])-2], Subject: m[2]}, true
+		},
+	})
+
+	register(&Convention{
+		Name:    "plain",
+		Summary: `a plain imperative sentence, e.g. "Add user login"`,
+		Guidance: `Write the subject as a plain imperative sentence starting with a capital letter, without a ` +
+			`type or scope prefix and without a trailing period (e.g., "Add user login functionality").`,
+		validate: func(c *Convention, subject string) error {
+			if r, _ := utf8.DecodeRuneInString(subject); unicode.IsLower(r) {
+				return fmt.Errorf(`subject "%s" should start with a capital letter`, subject)
+			}
+			if strings.HasSuffix(subject, ".") {
+				return fmt.Errorf(`subject "%s" shouldn't end with a period`, subject)
+			}
+			if m := conventionalPattern.FindStringSubmatch(subject); m != nil && isKnownType(m[1]) {
+				return fmt.Errorf(`subject "%s" shouldn't have a type prefix`, subject)
+			}
+			return nil
+		},
+		header: func(m Message) string {
+			return upperFirst(m.Subject)
+		},
+		parse: func(subject string) (Message, bool) {
+			return Message{Subject: subject}, true
+		},
+	})
+}
+
+// IsConventional reports whether subject has the "type(scope)!: summary"
+// form of Conventional Commits, whatever its type. It is the same check the
+// conventional convention's Validate and Parse make.
+func IsConventional(subject string) bool {
+	return conventionalPattern.MatchString(subject)
+}
+
+// parseTyped parses "type(scope)!: summary" subjects.
+func parseTyped(subject string) (Message, bool) {
+	m := conventionalPattern.FindStringSubmatch(subject)
+	if m == nil {
+		return Message{Subject: subject}, false
+	}
+	return Message{Type: m[1], Scope: strings.Trim(m[2], "()"), Breaking: m[3] == "!", Subject: m[4]}, true
+}
+
+func isEmoji(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r > unicode.MaxLatin1 && !unicode.IsLetter(r)
+}
+
+func checkType(typ string, types []string) error {
+	for _, t := range types {
+		if t == typ {
+			return nil
+		}
+	}
+	return &TypeError{Type: typ, Types: types}
+}
+
+func isKnownType(typ string) bool {
+	return checkType(strings.ToLower(typ), conventionalTypes) == nil
+}
@@ -0,0 +1,137 @@
+package convention
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	for _, name := range []string{"conventional", "angular", "gitmoji", "kernel", "plain"} {
+		c, err := Get(name)
+		if err != nil {
+			t.Fatalf("Get(%q) failed: %v", name, err)
+		}
+		if c.Name != name || c.Guidance == "" || c.Summary == "" {
+			t.Errorf("Get(%q) = %+v, expected a named convention with guidance", name, c)
+		}
+	}
+
+	_, err := Get("semantic")
+	if err == nil || !strings.Contains(err.Error(), "conventional, gitmoji, kernel, plain") {
+		t.Errorf("Expected an error listing the conventions, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		convention string
+		message    string
+		wantErr    string // empty if the message is valid
+	}{
+		{"conventional", "feat: add login", ""},
+		{"conventional", "fix(parser)!: reject empty input\n\nBREAKING CHANGE: empty input is an error", ""},
+		{"conventional", "  docs(readme): explain flags  ", ""},
+		{"conventional", "Add login", "isn't of the form"},
+		{"conventional", "feature: add login", "type 'feature' isn't one of"},
+		{"conventional", "\n\n", "subject is empty"},
+
+		{"angular", "fix(router): handle empty paths", ""},
+		{"angular", "chore: bump deps", "type 'chore' isn't one of"},
+		{"angular", "feat!: drop node 12", "isn't of the form"},
+		{"angular", "feat(Router): add guard", "isn't of the form"},
+		{"angular", "feat: Add guard", "lowercase letter"},
+		{"angular", "feat: add guard.", "period"},
+
+		{"gitmoji", ":bug: fix crash on empty input", ""},
+		{"gitmoji", "🐛 fix crash on empty input", ""},
+		{"gitmoji", "🐛", "doesn't start with a gitmoji"},
+		{"gitmoji", "fix: crash on empty input", "doesn't start with a gitmoji"},
+
+		{"kernel", "net: ipv4: fix refcount leak", ""},
+		{"kernel", "drm/i915: use the right register", ""},
+		{"kernel", "fix refcount leak", "isn't of the form"},
+		{"kernel", "mm: fix leak.", "period"},
+
+		{"plain", "Add user login", ""},
+		{"plain", "Note: document flags", ""},
+		{"plain", "add user login", "capital letter"},
+		{"plain", "Add user login.", "period"},
+		{"plain", "Feat: add user login", "type prefix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.convention+"/"+tt.message, func(t *testing.T) {
+			c, err := Get(tt.convention)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.Validate(tt.message)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		convention string
+		message    string
+		want       Message
+		ok         bool
+	}{
+		{"conventional", "feat(api)!: add login\n\nbody", Message{Type: "feat", Scope: "api", Breaking: true, Subject: "add login"}, true},
+		{"angular", "fix: handle empty paths", Message{Type: "fix", Subject: "handle empty paths"}, true},
+		{"gitmoji", ":bug: fix crash", Message{Type: ":bug:", Subject: "fix crash"}, true},
+		{"gitmoji", "🐛 fix crash", Message{Type: "🐛", Subject: "fix crash"}, true},
+		{"kernel", "net: ipv4: fix leak", Message{Scope: "net: ipv4", Subject: "fix leak"}, true},
+		{"plain", "Add login", Message{Subject: "Add login"}, true},
+		{"conventional", "Add login", Message{Subject: "Add login"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.convention+"/"+tt.message, func(t *testing.T) {
+			c, _ := Get(tt.convention)
+			got, ok := c.Parse(tt.message)
+			if ok != tt.ok || got.Type != tt.want.Type || got.Scope != tt.want.Scope ||
+				got.Subject != tt.want.Subject || got.Breaking != tt.want.Breaking {
+				t.Errorf("Parse() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestIsConventional(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    bool
+	}{
+		{"feat: add login", true},
+		{"fix(api): handle nil response", true},
+		{"refactor!: drop legacy config", true},
+		{"feat(ui)!: redesign settings page", true},
+		{"chore(deps/go.mod): bump cobra", true},
+		{"Add login", false},
+		{"feat:missing space", false},
+		{"feat(: broken scope", false},
+		{"feat(): empty scope", false},
+		{"wip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			if got := IsConventional(tt.subject); got != tt.want {
+				t.Errorf("IsConventional(%q) = %v, want %v", tt.subject, got, tt.want)
+			}
+			// the conventional convention accepts the same subjects
+			c, _ := Get("conventional")
+			if _, ok := c.Parse(tt.subject); ok != tt.want {
+				t.Errorf("Parse(%q) ok = %v, want %v", tt.subject, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package convention
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BodyWidth is the column message bodies are wrapped at.
+const BodyWidth = 72
+
+// Message is a commit message broken into the parts conventions are built
+// from. Parts a convention doesn't use are ignored.
+type Message struct {
+	Type     string
+	Scope    string
+	Subject  string
+	Body     string
+	Breaking bool
+	Footers  []Footer
+}
+
+// Footer is a git trailer such as "Refs: #123".
+type Footer struct {
+	Token string
+	Value string
+}
+
+// Format assembles m into a commit message: the subject line in the form the
+// convention asks for, then the body wrapped at BodyWidth and the footers,
+// each separated by a blank line.
+func (c *Convention) Format(m Message) string {
+	m.Type = strings.ToLower(oneLine(m.Type))
+	if !c.Fields.Type {
+		m.Type = ""
+	}
+	m.Scope = strings.Trim(oneLine(m.Scope), "()")
+	if !c.Fields.Scope {
+		m.Scope = ""
+	}
+	m.Subject = strings.TrimRight(oneLine(m.Subject), ".")
+	m.Breaking = m.Breaking && c.Fields.Breaking
+
+	parts := []string{c.header(m)}
+	if body := Wrap(strings.TrimSpace(m.Body), BodyWidth); body != "" {
+		parts = append(parts, body)
+	}
+	var footers []string
+	for _, f := range m.Footers {
+		token, value := footerToken(f.Token), strings.TrimSpace(f.Value)
+		if token != "" && value != "" {
+			footers = append(footers, token+": "+value)
+		}
+	}
+	if len(footers) > 0 {
+		parts = append(parts, strings.Join(footers, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// footerToken normalizes a trailer token, which git doesn't allow to contain
+// spaces, except for the "BREAKING CHANGE" token of conventional commits.
+func footerToken(token string) string {
+	token = strings.TrimSuffix(oneLine(token), ":")
+	if strings.EqualFold(token, "BREAKING CHANGE") || strings.EqualFold(token, "BREAKING-CHANGE") {
+		return "BREAKING CHANGE"
+	}
+	return strings.Join(strings.Fields(token), "-")
+}
+
+var listItemPattern = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+`)
+
+// Wrap wraps the lines of text at width, keeping list items indented under
+// their marker. Indented lines, such as code, are left alone.
+func Wrap(text string, width int) string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t")
+		prefix, indent := "", ""
+		if marker := listItemPattern.FindString(line); marker != "" {
+			line = line[len(marker):]
+			prefix = strings.TrimRight(marker, " \t") + " "
+			indent = strings.Repeat(" ", utf8.RuneCountInString(prefix))
+		} else if line != strings.TrimLeft(line, " \t") {
+			out = append(out, line)
+			continue
+		}
+
+		current := prefix
+		for _, word := range strings.Fields(line) {
+			switch {
+			case current == prefix || current == indent:
+				current += word
+			case utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) > width:
+				out = append(out, current)
+				current = indent + word
+			default:
+				current += " " + word
+			}
+		}
+		out = append(out, current)
+	}
+	return strings.Join(out, "\n")
+}
+
+// containerDirs hold a project's code without naming a part of it, so the
+// directory below them makes a better scope.
+var containerDirs = map[string]bool{
+	"internal": true, "pkg": true, "src": true, "lib": true, "app": true, "packages": true,
+}
+
+// InferScopes returns the scopes a change to files could have: the top-level
+// directory of each file, or the one below it for directories such as src
+// and internal. Files at the root of the repository have no scope.
+func InferScopes(files []string) []string {
+	seen := map[string]bool{}
+	var scopes []string
+	for _, file := range files {
+		dirs := strings.Split(path.Dir(file), "/")
+		if len(dirs) > 1 && containerDirs[dirs[0]] {
+			dirs = dirs[1:]
+		}
+		scope := dirs[0]
+		if scope == "." || scope == "" || strings.HasPrefix(scope, ".") || seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+func parenthesize(scope string) string {
+	if scope == "" {
+		return ""
+	}
+	return "(" + scope + ")"
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// lowerFirst lowercases the first letter of s unless it starts an acronym
+// such as "API".
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	next, _ := utf8.DecodeRuneInString(s[size:])
+	if !unicode.IsUpper(r) || unicode.IsUpper(next) {
+		return s
+	}
+	return string(unicode.ToLower(r)) + s[size:]
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
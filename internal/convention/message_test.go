@@ -0,0 +1,105 @@
+package convention
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	m := Message{Type: "fix", Scope: "parser", Subject: "Reject empty input", Breaking: true}
+
+	tests := []struct {
+		convention string
+		message    Message
+		want       string
+	}{
+		{"conventional", m, "fix(parser)!: reject empty input"},
+		{"conventional", Message{Type: " FIX ", Subject: "handle the API error."}, "fix: handle the API error"},
+		{"conventional", Message{Type: "feat", Subject: "Use API v2"}, "feat: use API v2"},
+		{"angular", m, "fix(parser): reject empty input"},
+		{"gitmoji", Message{Type: "bug", Scope: "parser", Subject: "Reject empty input"}, ":bug: reject empty input"},
+		{"gitmoji", Message{Type: ":bug:", Subject: "reject empty input"}, ":bug: reject empty input"},
+		{"gitmoji", Message{Type: "🐛", Subject: "reject empty input"}, "🐛 reject empty input"},
+		{"kernel", Message{Type: "fix", Scope: "net: ipv4", Subject: "Fix refcount leak"}, "net: ipv4: fix refcount leak"},
+		{"plain", m, "Reject empty input"},
+		{"plain", Message{Subject: "add\nuser  login."}, "Add user login"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.convention+"/"+tt.want, func(t *testing.T) {
+			c, err := Get(tt.convention)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := c.Format(tt.message)
+			if got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+			if err := c.Validate(got); err != nil {
+				t.Errorf("Formatted message doesn't validate: %v", err)
+			}
+		})
+	}
+}
+
+func TestFormat_BodyAndFooters(t *testing.T) {
+	c, _ := Get("conventional")
+	got := c.Format(Message{
+		Type:    "feat",
+		Subject: "add login",
+		Body:    "\n- add form\n\nThe form posts to the session endpoint.\n",
+		Footers: []Footer{
+			{Token: "Refs", Value: "#12"},
+			{Token: "Reviewed by", Value: "Alice"},
+			{Token: "BREAKING-CHANGE:", Value: "sessions expire"},
+			{Token: "Empty", Value: " "},
+		},
+	})
+	want := "feat: add login\n\n- add form\n\nThe form posts to the session endpoint.\n\n" +
+		"Refs: #12\nReviewed-by: Alice\nBREAKING CHANGE: sessions expire"
+	if got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"short", "fits here", "fits here"},
+		{"paragraph", "one two three four five six", "one two\nthree four\nfive six"},
+		{"list item", "- one two three four five", "- one two\n  three\n  four five"},
+		{"numbered item", "10. one two three", "10. one two\n    three"},
+		{"long word", "abcdefghijklmnop x", "abcdefghijklmnop\nx"},
+		{"blank lines kept", "one\n\ntwo", "one\n\ntwo"},
+		{"indented code kept", "    one two three four five six", "    one two three four five six"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Wrap(tt.text, 11); got != tt.want {
+				t.Errorf("Wrap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferScopes(t *testing.T) {
+	files := []string{
+		"README.md",
+		"cmd/root.go",
+		"internal/llm/client.go",
+		"internal/llm/stream.go",
+		"internal/version.go",
+		"src/components/Button.tsx",
+		".github/workflows/test.yml",
+	}
+	want := []string{"cmd", "components", "internal", "llm"}
+	if got := InferScopes(files); !slices.Equal(got, want) {
+		t.Errorf("InferScopes() = %v, want %v", got, want)
+	}
+	if got := InferScopes([]string{"go.mod"}); len(got) != 0 {
+		t.Errorf("Expected no scopes for root files, got %v", strings.Join(got, ","))
+	}
+}
@@ -0,0 +1,87 @@
+package convention
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Placement is where a ticket reference goes in a message.
+type Placement string
+
+const (
+	// PlaceFooter adds a "Refs: PROJ-123" trailer.
+	PlaceFooter Placement = "footer"
+	// PlaceSubject prefixes the subject, as in "[PROJ-123] feat: add login".
+	PlaceSubject Placement = "subject"
+)
+
+// ParsePlacement parses a placement name, where "" means the footer.
+func ParsePlacement(s string) (Placement, error) {
+	switch Placement(s) {
+	case "", PlaceFooter:
+		return PlaceFooter, nil
+	case PlaceSubject:
+		return PlaceSubject, nil
+	}
+	return "", fmt.Errorf("unknown ticket placement '%s' (expected footer or subject)", s)
+}
+
+// TicketFromBranch returns the ticket ID the first matching pattern finds in
+// branch: the first capture group if the pattern has one, otherwise the whole
+// match. An empty string is returned if no pattern matches.
+func TicketFromBranch(branch string, patterns []*regexp.Regexp) string {
+	for _, pattern := range patterns {
+		m := pattern.FindStringSubmatch(branch)
+		if m == nil {
+			continue
+		}
+		if len(m) > 1 {
+			return m[1]
+		}
+		return m[0]
+	}
+	return ""
+}
+
+// SubjectTicket splits a "[PROJ-123] " prefix matching pattern off subject.
+func SubjectTicket(subject string, pattern *regexp.Regexp) (ticket, rest string) {
+	if !strings.HasPrefix(subject, "[") {
+		return "", subject
+	}
+	inner, rest, found := strings.Cut(subject[1:], "] ")
+	if !found || pattern == nil || !pattern.MatchString(inner) {
+		return "", subject
+	}
+	return inner, rest
+}
+
+// AddTicket adds a reference to ticket to message, unless the message
+// already mentions it.
+func AddTicket(message, ticket string, placement Placement) string {
+	if ticket == "" || strings.Contains(message, ticket) {
+		return message
+	}
+	if placement == PlaceSubject {
+		return "[" + ticket + "] " + message
+	}
+
+	footer := "Refs: " + ticket
+	paragraphs := strings.Split(message, "\n\n")
+	last := paragraphs[len(paragraphs)-1]
+	if len(paragraphs) > 1 && isTrailerBlock(last) {
+		return message + "\n" + footer
+	}
+	return message + "\n\n" + footer
+}
+
+var trailerLinePattern = regexp.MustCompile(`^([A-Za-z][\w-]*|BREAKING CHANGE): \S`)
+
+func isTrailerBlock(paragraph string) bool {
+	for _, line := range strings.Split(paragraph, "\n") {
+		if !trailerLinePattern.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
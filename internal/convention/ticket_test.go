@@ -0,0 +1,78 @@
+package convention
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestTicketFromBranch(t *testing.T) {
+	patterns := []*regexp.Regexp{
+		regexp.MustCompile(`^hotfix/(\d+)`),
+		regexp.MustCompile(`[A-Z][A-Z0-9]+-\d+`),
+	}
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"feature/PROJ-1234-add-login", "PROJ-1234"},
+		{"PROJ-1234", "PROJ-1234"},
+		{"hotfix/42-crash", "42"},
+		{"bugfix/proj-1234-lowercase", ""},
+		{"main", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := TicketFromBranch(tt.branch, patterns); got != tt.want {
+			t.Errorf("TicketFromBranch(%q) = %q, want %q", tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestAddTicket(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   string
+		placement Placement
+		want      string
+	}{
+		{"footer", "feat: add login", PlaceFooter, "feat: add login\n\nRefs: PROJ-1"},
+		{"footer after body", "feat: add login\n\n- add form", PlaceFooter, "feat: add login\n\n- add form\n\nRefs: PROJ-1"},
+		{"footer with trailers", "feat: add login\n\nBREAKING CHANGE: sessions expire", PlaceFooter,
+			"feat: add login\n\nBREAKING CHANGE: sessions expire\nRefs: PROJ-1"},
+		{"subject", "feat: add login\n\n- add form", PlaceSubject, "[PROJ-1] feat: add login\n\n- add form"},
+		{"already referenced", "feat: add login\n\nCloses PROJ-1", PlaceSubject, "feat: add login\n\nCloses PROJ-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AddTicket(tt.message, "PROJ-1", tt.placement); got != tt.want {
+				t.Errorf("AddTicket() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if got := AddTicket("feat: add login", "", PlaceSubject); got != "feat: add login" {
+		t.Errorf("Expected no change without a ticket, got %q", got)
+	}
+}
+
+func TestSubjectTicket(t *testing.T) {
+	pattern := regexp.MustCompile(`^[A-Z]+-\d+$`)
+	if ticket, rest := SubjectTicket("[PROJ-1] feat: add login", pattern); ticket != "PROJ-1" || rest != "feat: add login" {
+		t.Errorf("SubjectTicket() = %q, %q", ticket, rest)
+	}
+	for _, subject := range []string{"[wip] feat: add login", "feat: add login", "[PROJ-1]feat"} {
+		if ticket, rest := SubjectTicket(subject, pattern); ticket != "" || rest != subject {
+			t.Errorf("SubjectTicket(%q) = %q, %q, expected no ticket", subject, ticket, rest)
+		}
+	}
+}
+
+func TestParsePlacement(t *testing.T) {
+	for s, want := range map[string]Placement{"": PlaceFooter, "footer": PlaceFooter, "subject": PlaceSubject} {
+		if got, err := ParsePlacement(s); err != nil || got != want {
+			t.Errorf("ParsePlacement(%q) = %q, %v", s, got, err)
+		}
+	}
+	if _, err := ParsePlacement("body"); err == nil {
+		t.Error("Expected an error for an unknown placement")
+	}
+}
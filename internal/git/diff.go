@@ -0,0 +1,454 @@
+package git
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// FileStatus is how a diff changes a file, using git's status letters.
+type FileStatus string
+
+const (
+	StatusAdded    FileStatus = "A"
+	StatusModified FileStatus = "M"
+	StatusDeleted  FileStatus = "D"
+	StatusRenamed  FileStatus = "R"
+	StatusCopied   FileStatus = "C"
+)
+
+// Diff is a parsed unified diff, as produced by git diff.
+type Diff struct {
+	Files []*FileDiff
+}
+
+// FileDiff is the part of a diff that changes one file.
+type FileDiff struct {
+	Status FileStatus
+	// OldPath is empty for an added file and NewPath for a deleted one.
+	OldPath string
+	NewPath string
+	// OldMode and NewMode are set when the file's mode is changed, and
+	// NewMode alone when it is added.
+	OldMode string
+	NewMode string
+	// Similarity is the percentage git gives a rename or copy.
+	Similarity int
+	Binary     bool
+	// Language is the file's language, see Language.
+	Language string
+	Hunks    []*Hunk
+}
+
+// Path returns the file's path after the change, or before it for a
+// deleted file.
+func (f *FileDiff) Path() string {
+	if f.NewPath == "" {
+		return f.OldPath
+	}
+	return f.NewPath
+}
+
+// Additions counts the lines the diff adds to the file.
+func (f *FileDiff) Additions() int {
+	n := 0
+	for _, h := range f.Hunks {
+		n += h.count(LineAdded)
+	}
+	return n
+}
+
+// Deletions counts the lines the diff removes from the file.
+func (f *FileDiff) Deletions() int {
+	n := 0
+	for _, h := range f.Hunks {
+		n += h.count(LineRemoved)
+	}
+	return n
+}
+
+// Additions counts the lines added across all files.
+func (d *Diff) Additions() int {
+	n := 0
+	for _, f := range d.Files {
+		n += f.Additions()
+	}
+	return n
+}
+
+// Deletions counts the lines removed across all files.
+func (d *Diff) Deletions() int {
+	n := 0
+	for _, f := range d.Files {
+		n += f.Deletions()
+	}
+	return n
+}
+
+// Hunk is a run of changed lines with their context.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	// Section is the text git shows after the range, usually the enclosing
+	// function.
+	Section string
+	Lines   []Line
+}
+
+func (h *Hunk) count(kind LineKind) int {
+	n := 0
+	for _, l := range h.Lines {
+		if l.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// String renders the hunk as it appears in a diff.
+func (h *Hunk) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+	if h.Section != "" {
+		b.WriteString(" " + h.Section)
+	}
+	b.WriteString("\n")
+	for _, l := range h.Lines {
+		b.WriteString(string(l.Kind) + l.Text + "\n")
+		if l.NoNewline {
+			b.WriteString("\\ No newline at end of file\n")
+		}
+	}
+	return b.String()
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// LineKind is the prefix of a line in a hunk.
+type LineKind byte
+
+const (
+	LineContext LineKind = ' '
+	LineAdded   LineKind = '+'
+	LineRemoved LineKind = '-'
+)
+
+// Line is a line of a hunk, without its prefix.
+type Line struct {
+	Kind LineKind
+	Text string
+	// NoNewline is set on the last line of a file that doesn't end in a
+	// newline.
+	NoNewline bool
+}
+
+// ParseDiff parses the output of git diff, git show, diff -u or
+// GetStagedChanges. Anything before the first file, such as a commit message,
+// is ignored, and blank context lines missing from the end, as when the
+// output was trimmed, are restored.
+func ParseDiff(diff string) (*Diff, error) {
+	p := diffParser{lines: strings.Split(strings.TrimRight(diff, "\n"), "\n")}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return &Diff{Files: p.files}, nil
+}
+
+type diffParser struct {
+	lines []string
+	i     int
+	files []*FileDiff
+}
+
+func (p *diffParser) parse() error {
+	var file *FileDiff
+	for ; p.i < len(p.lines); p.i++ {
+		line := p.lines[p.i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			file = p.startFile()
+			file.OldPath, file.NewPath = parseGitHeaderPaths(strings.TrimPrefix(line, "diff --git "))
+		case strings.HasPrefix(line, "diff --cc ") || strings.HasPrefix(line, "diff --combined "):
+			return fmt.Errorf("line %d: combined diffs of merges are not supported", p.i+1)
+		case strings.HasPrefix(line, "--- ") && p.i+1 < len(p.lines) && strings.HasPrefix(p.lines[p.i+1], "+++ "):
+			// the file header of diff -u has no "diff --git" line before it
+			if file == nil || len(file.Hunks) > 0 {
+				file = p.startFile()
+			}
+			file.OldPath = parseHeaderPath(strings.TrimPrefix(line, "--- "), "a/")
+			p.i++
+			file.NewPath = parseHeaderPath(strings.TrimPrefix(p.lines[p.i], "+++ "), "b/")
+		case strings.HasPrefix(line, "rename ") && strings.Contains(line, noContentChanges):
+			file = p.startFile()
+			parseCollapsedRename(file, line)
+		case strings.HasPrefix(line, "mode change "):
+			file = p.startFile()
+			parseCollapsedModeChange(file, line)
+		case file == nil:
+			// before the first file
+		case strings.HasPrefix(line, "@@ "):
+			hunk, err := p.parseHunk()
+			if err != nil {
+				return err
+			}
+			file.Hunks = append(file.Hunks, hunk)
+		default:
+			parseExtendedHeader(file, line)
+		}
+	}
+
+	for _, f := range p.files {
+		switch {
+		case f.OldPath == "":
+			f.Status = StatusAdded
+		case f.NewPath == "":
+			f.Status = StatusDeleted
+		case f.Status == "":
+			f.Status = StatusModified
+		}
+		f.Language = Language(f.Path())
+	}
+	return nil
+}
+
+func (p *diffParser) startFile() *FileDiff {
+	f := &FileDiff{}
+	p.files = append(p.files, f)
+	return f
+}
+
+// noContentChanges ends the line GetStagedChanges collapses a pure rename
+// into.
+const noContentChanges = " (no content changes)"
+
+// parseCollapsedRename parses a line such as "rename old.go => new.go (no
+// content changes), mode change 100644 => 100755", as GetStagedChanges
+// describes a pure rename.
+func parseCollapsedRename(f *FileDiff, line string) {
+	paths, modes, _ := strings.Cut(strings.TrimPrefix(line, "rename "), noContentChanges)
+	f.Status, f.Similarity = StatusRenamed, 100
+	f.OldPath, f.NewPath, _ = strings.Cut(paths, " => ")
+	if modes, ok := strings.CutPrefix(modes, ", mode change "); ok {
+		f.OldMode, f.NewMode, _ = strings.Cut(modes, " => ")
+	}
+}
+
+// parseCollapsedModeChange parses a line such as "mode change 100644 =>
+// 100755 run.sh", as GetStagedChanges describes a mode change.
+func parseCollapsedModeChange(f *FileDiff, line string) {
+	if fields := strings.SplitN(line, " ", 6); len(fields) == 6 {
+		f.OldMode, f.NewMode = fields[2], fields[4]
+		f.OldPath, f.NewPath = fields[5], fields[5]
+	}
+}
+
+// parseExtendedHeader records what a git extended header line, such as
+// "new file mode 100644" or "rename from old.go", says about the file.
+func parseExtendedHeader(f *FileDiff, line string) {
+	switch {
+	case strings.HasPrefix(line, "new file mode "):
+		f.OldPath, f.NewMode = "", strings.TrimPrefix(line, "new file mode ")
+	case strings.HasPrefix(line, "deleted file mode "):
+		f.NewPath, f.OldMode = "", strings.TrimPrefix(line, "deleted file mode ")
+	case strings.HasPrefix(line, "old mode "):
+		f.OldMode = strings.TrimPrefix(line, "old mode ")
+	case strings.HasPrefix(line, "new mode "):
+		f.NewMode = strings.TrimPrefix(line, "new mode ")
+	case strings.HasPrefix(line, "rename from "):
+		f.Status, f.OldPath = StatusRenamed, unquotePath(strings.TrimPrefix(line, "rename from "))
+	case strings.HasPrefix(line, "rename to "):
+		f.Status, f.NewPath = StatusRenamed, unquotePath(strings.TrimPrefix(line, "rename to "))
+	case strings.HasPrefix(line, "copy from "):
+		f.Status, f.OldPath = StatusCopied, unquotePath(strings.TrimPrefix(line, "copy from "))
+	case strings.HasPrefix(line, "copy to "):
+		f.Status, f.NewPath = StatusCopied, unquotePath(strings.TrimPrefix(line, "copy to "))
+	case strings.HasPrefix(line, "similarity index "):
+		f.Similarity, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, "similarity index "), "%"))
+	case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch" ||
+		strings.HasPrefix(line, "Binary file added: ") || strings.HasPrefix(line, "Binary file deleted: ") ||
+		strings.HasPrefix(line, "Binary file changed"):
+		// the last are how GetStagedChanges describes binary files
+		f.Binary = true
+	}
+}
+
+// parseHunk parses the hunk starting at the current line, leaving the parser
+// on its last line. The line counts in the hunk header say where the hunk
+// ends, so removed lines such as "--- x" aren't mistaken for headers.
+func (p *diffParser) parseHunk() (*Hunk, error) {
+	hunk, err := parseHunkHeader(p.lines[p.i])
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", p.i+1, err)
+	}
+	oldLeft, newLeft := hunk.OldLines, hunk.NewLines
+	for oldLeft > 0 || newLeft > 0 || p.i+1 < len(p.lines) && strings.HasPrefix(p.lines[p.i+1], `\`) {
+		if p.i+1 >= len(p.lines) {
+			if oldLeft != newLeft {
+				return nil, fmt.Errorf("line %d: hunk ends early", p.i+1)
+			}
+			// trimming the diff drops trailing blank context lines
+			for ; oldLeft > 0; oldLeft-- {
+				hunk.Lines = append(hunk.Lines, Line{Kind: LineContext})
+			}
+			break
+		}
+		p.i++
+		line := p.lines[p.i]
+		if line == "" {
+			// context lines that were blank can lose their space
+			line = " "
+		}
+		kind := LineKind(line[0])
+		switch kind {
+		case LineContext:
+			oldLeft--
+			newLeft--
+		case LineRemoved:
+			oldLeft--
+		case LineAdded:
+			newLeft--
+		case '\\':
+			if n := len(hunk.Lines); n > 0 {
+				hunk.Lines[n-1].NoNewline = true
+			}
+			continue
+		default:
+			return nil, fmt.Errorf("line %d: unexpected line in hunk: %q", p.i+1, line)
+		}
+		if oldLeft < 0 || newLeft < 0 {
+			return nil, fmt.Errorf("line %d: hunk has more lines than its header says", p.i+1)
+		}
+		hunk.Lines = append(hunk.Lines, Line{Kind: kind, Text: line[1:]})
+	}
+	return hunk, nil
+}
+
+// parseHunkHeader parses a line such as "@@ -1,4 +1,5 @@ func main() {".
+func parseHunkHeader(line string) (*Hunk, error) {
+	ranges, section, found := strings.Cut(strings.TrimPrefix(line, "@@ "), " @@")
+	oldRange, newRange, ok := strings.Cut(ranges, " ")
+	if !found || !ok || !strings.HasPrefix(oldRange, "-") || !strings.HasPrefix(newRange, "+") {
+		return nil, fmt.Errorf("invalid hunk header: %q", line)
+	}
+	h := &Hunk{Section: strings.TrimPrefix(section, " ")}
+	var err error
+	if h.OldStart, h.OldLines, err = parseHunkRange(oldRange[1:]); err != nil {
+		return nil, fmt.Errorf("invalid hunk header: %q", line)
+	}
+	if h.NewStart, h.NewLines, err = parseHunkRange(newRange[1:]); err != nil {
+		return nil, fmt.Errorf("invalid hunk header: %q", line)
+	}
+	return h, nil
+}
+
+// parseHunkRange parses "start,lines", where lines defaults to 1.
+func parseHunkRange(s string) (int, int, error) {
+	startText, linesText, found := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return start, 1, nil
+	}
+	lines, err := strconv.Atoi(linesText)
+	return start, lines, err
+}
+
+// parseGitHeaderPaths returns the paths from the "a/old b/new" part of a
+// "diff --git" line. They can only be told apart reliably when quoted or
+// equal, so the "---", "+++" and rename lines that follow take precedence.
+func parseGitHeaderPaths(s string) (string, string) {
+	if strings.HasPrefix(s, `"`) {
+		if end := closingQuote(s); end > 0 {
+			return parseHeaderPath(s[:end+1], "a/"), parseHeaderPath(strings.TrimSpace(s[end+1:]), "b/")
+		}
+	}
+	// "a/path b/path" with the same path twice
+	if n := len(s) / 2; len(s)%2 == 1 && s[n] == ' ' &&
+		strings.HasPrefix(s, "a/") && s[n+1:n+3] == "b/" && s[2:n] == s[n+3:] {
+		return s[2:n], s[n+3:]
+	}
+	if i := strings.LastIndex(s, " b/"); i >= 0 {
+		return parseHeaderPath(s[:i], "a/"), parseHeaderPath(s[i+1:], "b/")
+	}
+	return "", ""
+}
+
+// parseHeaderPath returns the path from a "---" or "+++" line, dropping its
+// prefix and any timestamp diff -u adds. /dev/null becomes "".
+func parseHeaderPath(s, prefix string) string {
+	if !strings.HasPrefix(s, `"`) {
+		s, _, _ = strings.Cut(s, "\t")
+	}
+	s = unquotePath(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// unquotePath decodes a path git quoted because it has special characters,
+// e.g. "caf\303\251.txt".
+func unquotePath(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return s
+}
+
+// closingQuote returns the index of the quote ending the quoted string s
+// starts with, or -1.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// languages maps file extensions to the language of the file.
+var languages = map[string]string{
+	".go": "Go", ".py": "Python", ".js": "JavaScript", ".mjs": "JavaScript", ".cjs": "JavaScript",
+	".jsx": "JavaScript", ".ts": "TypeScript", ".tsx": "TypeScript", ".java": "Java",
+	".kt": "Kotlin", ".kts": "Kotlin", ".scala": "Scala", ".c": "C", ".h": "C",
+	".cc": "C++", ".cpp": "C++", ".cxx": "C++", ".hpp": "C++", ".cs": "C#",
+	".rb": "Ruby", ".rs": "Rust", ".php": "PHP", ".swift": "Swift", ".m": "Objective-C",
+	".sh": "Shell", ".bash": "Shell", ".zsh": "Shell", ".lua": "Lua", ".dart": "Dart",
+	".ex": "Elixir", ".exs": "Elixir", ".erl": "Erlang", ".hs": "Haskell", ".clj": "Clojure",
+	".sql": "SQL", ".html": "HTML", ".css": "CSS", ".scss": "SCSS", ".vue": "Vue",
+	".svelte": "Svelte", ".md": "Markdown", ".rst": "reStructuredText", ".json": "JSON",
+	".yaml": "YAML", ".yml": "YAML", ".toml": "TOML", ".xml": "XML", ".proto": "Protocol Buffers",
+	".tf": "Terraform", ".tmpl": "Template",
+}
+
+// filenameLanguages maps well-known file names to their language.
+var filenameLanguages = map[string]string{
+	"Makefile": "Makefile", "GNUmakefile": "Makefile", "Dockerfile": "Dockerfile",
+	"go.mod": "Go Module", "go.sum": "Go Module", "CMakeLists.txt": "CMake",
+}
+
+// Language guesses the language of the file at path from its name, returning
+// an empty string when it isn't known.
+func Language(filePath string) string {
+	name := path.Base(filePath)
+	if lang, ok := filenameLanguages[name]; ok {
+		return lang
+	}
+	return languages[strings.ToLower(path.Ext(name))]
+}
@@ -0,0 +1,433 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// file is what a test expects of a FileDiff.
+type file struct {
+	Status     FileStatus
+	OldPath    string
+	NewPath    string
+	OldMode    string
+	NewMode    string
+	Similarity int
+	Binary     bool
+	Language   string
+	Hunks      int
+	Additions  int
+	Deletions  int
+}
+
+func summarize(f *FileDiff) file {
+	return file{
+		Status: f.Status, OldPath: f.OldPath, NewPath: f.NewPath, OldMode: f.OldMode, NewMode: f.NewMode,
+		Similarity: f.Similarity, Binary: f.Binary, Language: f.Language,
+		Hunks: len(f.Hunks), Additions: f.Additions(), Deletions: f.Deletions(),
+	}
+}
+
+func TestParseDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want []file
+	}{
+		{
+			name: "modified file with two hunks",
+			diff: `diff --git a/cmd/root.go b/cmd/root.go
+index 3f1c2a4..8e2b7d1 100644
+--- a/cmd/root.go
++++ b/cmd/root.go
+@@ -12,6 +12,9 @@ import (
+ 	"github.com/spf13/cobra"
+ )
+
+-var verbose bool
++var (
++	verbose bool
++	quiet   bool
++)
+
+ var rootCmd = &cobra.Command{
+ 	Use:   "diffgpt",
+@@ -40,4 +43,5 @@ func init() {
+ 	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
++	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "quiet output")
+ }
+
+ func Execute() {
+`,
+			want: []file{{Status: StatusModified, OldPath: "cmd/root.go", NewPath: "cmd/root.go",
+				Language: "Go", Hunks: 2, Additions: 5, Deletions: 1}},
+		},
+		{
+			name: "added and deleted files",
+			diff: `diff --git a/docs/usage.md b/docs/usage.md
+new file mode 100644
+index 0000000..e69de29
+--- /dev/null
++++ b/docs/usage.md
+@@ -0,0 +1,3 @@
++# Usage
++
++Run diffgpt.
+diff --git a/scripts/old.sh b/scripts/old.sh
+deleted file mode 100755
+index 5c6f2a1..0000000
+--- a/scripts/old.sh
++++ /dev/null
+@@ -1,2 +0,0 @@
+-#!/bin/sh
+-echo old
+`,
+			want: []file{
+				{Status: StatusAdded, NewPath: "docs/usage.md", NewMode: "100644", Language: "Markdown",
+					Hunks: 1, Additions: 3},
+				{Status: StatusDeleted, OldPath: "scripts/old.sh", OldMode: "100755", Language: "Shell",
+					Hunks: 1, Deletions: 2},
+			},
+		},
+		{
+			name: "empty new file",
+			diff: `diff --git a/.keep b/.keep
+new file mode 100644
+index 0000000..e69de29
+`,
+			want: []file{{Status: StatusAdded, NewPath: ".keep", NewMode: "100644"}},
+		},
+		{
+			name: "pure rename and rename with edits",
+			diff: `diff --git a/internal/util.go b/internal/strutil/util.go
+similarity index 100%
+rename from internal/util.go
+rename to internal/strutil/util.go
+diff --git a/README b/README.md
+similarity index 85%
+rename from README
+rename to README.md
+index 1a2b3c4..5d6e7f8 100644
+--- a/README
++++ b/README.md
+@@ -1,3 +1,3 @@
+-diffgpt
+-=======
++# diffgpt
++
+ Generate commit messages.
+`,
+			want: []file{
+				{Status: StatusRenamed, OldPath: "internal/util.go", NewPath: "internal/strutil/util.go",
+					Similarity: 100, Language: "Go"},
+				{Status: StatusRenamed, OldPath: "README", NewPath: "README.md", Similarity: 85,
+					Language: "Markdown", Hunks: 1, Additions: 2, Deletions: 2},
+			},
+		},
+		{
+			name: "copy",
+			diff: `diff --git a/config.yaml b/config.example.yaml
+similarity index 90%
+copy from config.yaml
+copy to config.example.yaml
+index 1111111..2222222 100644
+--- a/config.yaml
++++ b/config.example.yaml
+@@ -1 +1 @@
+-api_key: secret
++api_key: <your key>
+`,
+			want: []file{{Status: StatusCopied, OldPath: "config.yaml", NewPath: "config.example.yaml",
+				Similarity: 90, Language: "YAML", Hunks: 1, Additions: 1, Deletions: 1}},
+		},
+		{
+			name: "mode change of a path with spaces",
+			diff: `diff --git a/build all.sh b/build all.sh
+old mode 100644
+new mode 100755
+`,
+			want: []file{{Status: StatusModified, OldPath: "build all.sh", NewPath: "build all.sh",
+				OldMode: "100644", NewMode: "100755", Language: "Shell"}},
+		},
+		{
+			name: "binary files",
+			diff: `diff --git a/assets/logo.png b/assets/logo.png
+new file mode 100644
+index 0000000..9f2a3b1
+Binary files /dev/null and b/assets/logo.png differ
+diff --git a/assets/icon.ico b/assets/icon.ico
+index 1234567..89abcde 100644
+GIT binary patch
+literal 12
+TcmZQzU|?VZVi0)7Z~+qp
+
+literal 10
+RcmZQzU|?VZVn6@_0RRBm
+
+`,
+			want: []file{
+				{Status: StatusAdded, NewPath: "assets/logo.png", NewMode: "100644", Binary: true},
+				{Status: StatusModified, OldPath: "assets/icon.ico", NewPath: "assets/icon.ico", Binary: true},
+			},
+		},
+		{
+			name: "quoted non-ascii path",
+			diff: `diff --git "a/docs/caf\303\251.md" "b/docs/caf\303\251.md"
+index 1111111..2222222 100644
+--- "a/docs/caf\303\251.md"
++++ "b/docs/caf\303\251.md"
+@@ -1 +1,2 @@
+ # Café
++Open all day.
+`,
+			want: []file{{Status: StatusModified, OldPath: "docs/café.md", NewPath: "docs/café.md",
+				Language: "Markdown", Hunks: 1, Additions: 1}},
+		},
+		{
+			name: "lines that look like headers",
+			diff: `diff --git a/notes.txt b/notes.txt
+index 1111111..2222222 100644
+--- a/notes.txt
++++ b/notes.txt
+@@ -1,3 +1,3 @@
+--- a/removed.txt
+-++ b/removed.txt
++diff --git a/x b/x
++@@ -1 +1 @@
+ done
+`,
+			want: []file{{Status: StatusModified, OldPath: "notes.txt", NewPath: "notes.txt",
+				Hunks: 1, Additions: 2, Deletions: 2}},
+		},
+		{
+			name: "no newline at end of file and a blank context line",
+			diff: "diff --git a/main.py b/main.py\nindex 1111111..2222222 100644\n--- a/main.py\n+++ b/main.py\n" +
+				"@@ -1,3 +1,3 @@\n def main():\n\n-    pass\n\\ No newline at end of file\n+    run()\n\\ No newline at end of file\n",
+			want: []file{{Status: StatusModified, OldPath: "main.py", NewPath: "main.py",
+				Language: "Python", Hunks: 1, Additions: 1, Deletions: 1}},
+		},
+		{
+			name: "git show output",
+			diff: `commit 8e2b7d1c0a9f4e3b2d1c0a9f8e7d6c5b4a3f2e1d
+Author: Alice <alice@example.com>
+Date:   Mon Jan 6 10:00:00 2025 +0100
+
+    fix: handle empty input
+
+    --- not a diff
+    +++ still not a diff
+
+diff --git a/Makefile b/Makefile
+index 1111111..2222222 100644
+--- a/Makefile
++++ b/Makefile
+@@ -1,2 +1,2 @@
+ build:
+-	go build
++	go build ./...
+`,
+			want: []file{{Status: StatusModified, OldPath: "Makefile", NewPath: "Makefile",
+				Language: "Makefile", Hunks: 1, Additions: 1, Deletions: 1}},
+		},
+		{
+			name: "diff -u with timestamps",
+			diff: `--- src/app.ts	2025-01-06 10:00:00.000000000 +0100
++++ src/app.ts	2025-01-06 10:05:00.000000000 +0100
+@@ -1,2 +1,2 @@
+-const port = 3000;
++const port = 8080;
+ listen(port);
+--- /dev/null	1970-01-01 01:00:00.000000000 +0100
++++ src/new.ts	2025-01-06 10:05:00.000000000 +0100
+@@ -0,0 +1 @@
++export {};
+`,
+			want: []file{
+				{Status: StatusModified, OldPath: "src/app.ts", NewPath: "src/app.ts", Language: "TypeScript",
+					Hunks: 1, Additions: 1, Deletions: 1},
+				{Status: StatusAdded, NewPath: "src/new.ts", Language: "TypeScript", Hunks: 1, Additions: 1},
+			},
+		},
+		{
+			name: "collapsed by GetStagedChanges",
+			diff: `R  a.sh => b.sh
+M  run.sh
+2 files changed, 0 insertions(+), 0 deletions(-)
+
+rename a.sh => b.sh (no content changes), mode change 100644 => 100755
+
+mode change 100644 => 100755 run.sh
+`,
+			want: []file{
+				{Status: StatusRenamed, OldPath: "a.sh", NewPath: "b.sh", OldMode: "100644", NewMode: "100755",
+					Similarity: 100, Language: "Shell"},
+				{Status: StatusModified, OldPath: "run.sh", NewPath: "run.sh", OldMode: "100644", NewMode: "100755",
+					Language: "Shell"},
+			},
+		},
+		{
+			name: "empty",
+			diff: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParseDiff(tt.diff)
+			if err != nil {
+				t.Fatalf("ParseDiff() failed: %v", err)
+			}
+			if len(d.Files) != len(tt.want) {
+				t.Fatalf("got %d files, want %d", len(d.Files), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got := summarize(d.Files[i]); got != want {
+					t.Errorf("file %d = %+v\nwant %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDiff_Hunks(t *testing.T) {
+	diff := "diff --git a/main.py b/main.py\nindex 1111111..2222222 100644\n--- a/main.py\n+++ b/main.py\n" +
+		"@@ -1,3 +1,3 @@ class App:\n def main():\n\n-    pass\n\\ No newline at end of file\n+    run()\n\\ No newline at end of file\n"
+	d, err := ParseDiff(diff)
+	if err != nil {
+		t.Fatalf("ParseDiff() failed: %v", err)
+	}
+	h := d.Files[0].Hunks[0]
+	if h.OldStart != 1 || h.OldLines != 3 || h.NewStart != 1 || h.NewLines != 3 || h.Section != "class App:" {
+		t.Errorf("Unexpected hunk header: %+v", h)
+	}
+	want := []Line{
+		{Kind: LineContext, Text: "def main():"},
+		{Kind: LineContext, Text: ""},
+		{Kind: LineRemoved, Text: "    pass", NoNewline: true},
+		{Kind: LineAdded, Text: "    run()", NoNewline: true},
+	}
+	if len(h.Lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %+v", len(h.Lines), len(want), h.Lines)
+	}
+	for i := range want {
+		if h.Lines[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, h.Lines[i], want[i])
+		}
+	}
+
+	// rendering gives back the hunk, with the blank context line's space
+	wantHunk := "@@ -1,3 +1,3 @@ class App:\n def main():\n \n-    pass\n\\ No newline at end of file\n+    run()\n\\ No newline at end of file\n"
+	if got := h.String(); got != wantHunk {
+		t.Errorf("String() = %q, want %q", got, wantHunk)
+	}
+}
+
+func TestParseDiff_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want string
+	}{
+		{"invalid hunk header", "diff --git a/a b/a\n--- a/a\n+++ b/a\n@@ -x +1 @@\n+a\n", "invalid hunk header"},
+		{"hunk ends early", "diff --git a/a b/a\n--- a/a\n+++ b/a\n@@ -1,3 +1,3 @@\n a\n-b\n", "hunk ends early"},
+		{"unexpected line", "diff --git a/a b/a\n--- a/a\n+++ b/a\n@@ -1,2 +1,2 @@\n a\n?b\n", "unexpected line"},
+		{"combined diff", "diff --cc file.go\nindex 1111111,2222222..3333333\n", "combined diffs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDiff(tt.diff)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParseDiff_GitOutput(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Alice", "GIT_AUTHOR_EMAIL=alice@example.com",
+			"GIT_COMMITTER_NAME=Alice", "GIT_COMMITTER_EMAIL=alice@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("a\nb\n\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "init")
+	// the hunk ends on a blank context line, which trimming git's output loses
+	write("x\nb\n\n")
+	git("add", "-A")
+
+	staged, err := GetStagedDiff(dir)
+	if err != nil {
+		t.Fatalf("GetStagedDiff() failed: %v", err)
+	}
+	git("commit", "-q", "-m", "edit")
+	sha, _, err := runGitCommand(dir, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := GetDiffForCommit(dir, sha)
+	if err != nil {
+		t.Fatalf("GetDiffForCommit() failed: %v", err)
+	}
+
+	for name, diff := range map[string]string{"staged": staged, "commit": committed} {
+		d, err := ParseDiff(diff)
+		if err != nil {
+			t.Fatalf("ParseDiff() of the %s diff failed: %v", name, err)
+		}
+		h := d.Files[0].Hunks[0]
+		if len(h.Lines) != 4 || h.Lines[3] != (Line{Kind: LineContext}) || d.Additions() != 1 || d.Deletions() != 1 {
+			t.Errorf("Unexpected hunk in the %s diff: %+v", name, h.Lines)
+		}
+	}
+}
+
+func TestDiffTotals(t *testing.T) {
+	d, err := ParseDiff("diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1,2 +1,3 @@\n-a\n+b\n+c\n d\n" +
+		"diff --git a/b.go b/b.go\ndeleted file mode 100644\n--- a/b.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-x\n")
+	if err != nil {
+		t.Fatalf("ParseDiff() failed: %v", err)
+	}
+	if d.Additions() != 2 || d.Deletions() != 2 {
+		t.Errorf("Additions, Deletions = %d, %d; want 2, 2", d.Additions(), d.Deletions())
+	}
+	if d.Files[1].Path() != "b.go" {
+		t.Errorf("Path() of a deleted file = %q, want b.go", d.Files[1].Path())
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	tests := map[string]string{
+		"main.go":                 "Go",
+		"web/src/App.TSX":         "TypeScript",
+		"Makefile":                "Makefile",
+		"build/Dockerfile":        "Dockerfile",
+		"go.mod":                  "Go Module",
+		"docs/guide.md":           "Markdown",
+		".github/workflows/t.yml": "YAML",
+		"LICENSE":                 "",
+		"assets/logo.png":         "",
+	}
+	for path, want := range tests {
+		if got := Language(path); got != want {
+			t.Errorf("Language(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package git
+
+// DiffSummary describes the size of a diff.
+type DiffSummary struct {
+	Files     []string
+	Additions int
+	Deletions int
+}
+
+// SummarizeDiff lists the files touched by a diff in any form ParseDiff
+// accepts and counts its added and removed lines. A diff that can't be parsed
+// summarizes as empty.
+func SummarizeDiff(diff string) DiffSummary {
+	d, err := ParseDiff(diff)
+	if err != nil {
+		return DiffSummary{}
+	}
+	s := DiffSummary{Additions: d.Additions(), Deletions: d.Deletions()}
+	for _, f := range d.Files {
+		s.Files = append(s.Files, f.Path())
+	}
+	return s
+}
@@ -2,14 +2,22 @@ package git
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
+
+	"github.com/kabilan108/diffgpt/internal/convention"
 )
 
+// emptyTreeSHA is git's well-known id of the empty tree, which the initial
+// commit is diffed against.
+const emptyTreeSHA = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
 type CommitInfo struct {
 	SHA     string
 	Subject string
@@ -17,7 +25,12 @@ type CommitInfo struct {
 
 // execute git commmand in a specific directory or cwd if dir is empty
 func runGitCommand(dir string, args ...string) (string, string, error) {
-	cmd := exec.Command("git", args...)
+	return runGitCommandContext(context.Background(), dir, args...)
+}
+
+// runGitCommandContext is like runGitCommand but kills git when ctx is done
+func runGitCommandContext(ctx context.Context, dir string, args ...string) (string, string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
 	if dir != "" {
 		cmd.Dir = dir
 	}
@@ -71,12 +84,67 @@ func GetRepoRoot(path string) (string, error) {
 	return absPath, nil
 }
 
-func GetCommitLog(repoPath, startRef string, count int) ([]CommitInfo, error) {
+// LogFilter narrows down the commits returned by GetCommitLog. The zero value
+// matches every commit.
+type LogFilter struct {
+	Author   string   // passed to git log --author
+	Since    string   // passed to git log --since
+	Until    string   // passed to git log --until
+	Paths    []string // pathspecs limiting the commits to those touching these paths
+	NoMerges bool
+
+	// The remaining filters are applied to the commit subject in Go.
+	MinSubjectLength int
+	ExcludeMessage   *regexp.Regexp
+	ConventionalOnly bool
+}
+
+// filtersSubjects reports whether the filter inspects subjects, in which case
+// git can't limit the number of commits for us.
+func (f LogFilter) filtersSubjects() bool {
+	return f.MinSubjectLength > 0 || f.ExcludeMessage != nil || f.ConventionalOnly
+}
+
+func (f LogFilter) matchSubject(subject string) bool {
+	if len(strings.TrimSpace(subject)) < f.MinSubjectLength {
+		return false
+	}
+	if f.ExcludeMessage != nil && f.ExcludeMessage.MatchString(subject) {
+		return false
+	}
+	if f.ConventionalOnly && !convention.IsConventional(subject) {
+		return false
+	}
+	return true
+}
+
+// GetCommitLog lists up to count commits reachable from startRef (HEAD if
+// empty) that match filter, newest first. A count of 0 or less lists them all.
+func GetCommitLog(repoPath, startRef string, count int, filter LogFilter) ([]CommitInfo, error) {
 	// Fix: Format string should not include space
-	args := []string{"log", "--format=format:%H %s", fmt.Sprintf("-n%d", count)}
+	args := []string{"log", "--format=format:%H %s"}
+	if count > 0 && !filter.filtersSubjects() {
+		args = append(args, fmt.Sprintf("-n%d", count))
+	}
+	if filter.Author != "" {
+		args = append(args, "--author="+filter.Author)
+	}
+	if filter.Since != "" {
+		args = append(args, "--since="+filter.Since)
+	}
+	if filter.Until != "" {
+		args = append(args, "--until="+filter.Until)
+	}
+	if filter.NoMerges {
+		args = append(args, "--no-merges")
+	}
 	if startRef != "" {
 		args = append(args, startRef)
 	}
+	if len(filter.Paths) > 0 {
+		args = append(args, "--")
+		args = append(args, filter.Paths...)
+	}
 
 	stdout, _, err := runGitCommand(repoPath, args...)
 	if err != nil {
@@ -99,11 +167,24 @@ func GetCommitLog(repoPath, startRef string, count int) ([]CommitInfo, error) {
 		if len(line) > hashEndIndex+1 {
 			subject = line[hashEndIndex+1:]
 		}
+		if !filter.matchSubject(subject) {
+			continue
+		}
 		commits = append(commits, CommitInfo{SHA: sha, Subject: subject})
+		if len(commits) == count {
+			break
+		}
 	}
 	return commits, nil
 }
 
+// IsAncestor reports whether commit is rev or one of its ancestors. A commit
+// that no longer exists, as after history was rewritten, isn't.
+func IsAncestor(repoPath, commit, rev string) bool {
+	_, _, err := runGitCommand(repoPath, "merge-base", "--is-ancestor", commit, rev)
+	return err == nil
+}
+
 // GetDiffForCommit retrieves the diff associated with a specific commit SHA.
 func GetDiffForCommit(repoPath, sha string) (string, error) {
 	// `git show` includes the diff. `--pretty=""` suppresses commit info.
@@ -113,7 +194,6 @@ func GetDiffForCommit(repoPath, sha string) (string, error) {
 	// Let's try `git show` first for simplicity.
 	// stdout, _, err := runGitCommand(repoPath, "show", "--pretty=format:%b", sha) // %b = body (includes diff)
 	// A potentially cleaner way: diff against parent. Handles initial commit via magic SHA.
-	emptyTreeSHA := "4b825dc642cb6eb9a060e54bf8d69288fbee4904" // Git's magic empty tree hash
 	parentRef := sha + "^"
 
 	// Check if the commit has a parent
@@ -141,6 +221,30 @@ func GetDiffForCommit(repoPath, sha string) (string, error) {
 	return stdout, nil
 }
 
+// GetCommitPatch returns the full message of a commit and the changes it
+// made against its first parent, prepared like GetStagedChanges. Both come
+// from a single git log, so learning from many commits stays fast.
+func GetCommitPatch(ctx context.Context, repoPath, sha string) (string, string, error) {
+	out, _, err := runGitCommandContext(ctx, repoPath, "log", "-1", "-p", "-m", "--first-parent", "--root",
+		"--find-renames", "--full-index", "--format=format:%P%x00%B%x00", sha)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get patch of commit %s: %w", sha, err)
+	}
+	parents, rest, _ := strings.Cut(out, "\x00")
+	message, diff, _ := strings.Cut(rest, "\x00")
+
+	// the initial commit is diffed against the empty tree
+	base := emptyTreeSHA
+	if fields := strings.Fields(parents); len(fields) > 0 {
+		base = fields[0]
+	}
+	changes, err := prepareChanges(ctx, repoPath, strings.TrimLeft(diff, "\n"), base, sha)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get changes of commit %s: %w", sha, err)
+	}
+	return strings.TrimSpace(message), changes, nil
+}
+
 func GetCommitMessage(repoPath, sha string) (string, error) {
 	stdout, _, err := runGitCommand(repoPath, "log", "-n", "1", "--pretty=format:%B", sha)
 	if err != nil {
@@ -149,6 +253,50 @@ func GetCommitMessage(repoPath, sha string) (string, error) {
 	return stdout, nil
 }
 
+// CommitMessage is the full message of a commit.
+type CommitMessage struct {
+	SHA     string
+	Message string
+}
+
+// GetCommitMessages returns the messages of the commits in revRange, newest
+// first. A range such as main..HEAD selects every commit in it, while a single
+// revision selects only that commit.
+func GetCommitMessages(repoPath, revRange string) ([]CommitMessage, error) {
+	args := []string{"log", "--format=format:%H%x00%B%x1e"}
+	if !strings.Contains(revRange, "..") && !strings.HasSuffix(revRange, "^!") {
+		args = append(args, "-n1")
+	}
+	args = append(args, revRange, "--")
+	stdout, _, err := runGitCommand(repoPath, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit messages for %s: %w", revRange, err)
+	}
+
+	var messages []CommitMessage
+	for _, record := range strings.Split(stdout, "\x1e") {
+		sha, message, found := strings.Cut(strings.TrimSpace(record), "\x00")
+		if !found {
+			continue
+		}
+		messages = append(messages, CommitMessage{SHA: sha, Message: strings.TrimSpace(message)})
+	}
+	return messages, nil
+}
+
+// GetHooksDir returns the directory git runs hooks from, honouring
+// core.hooksPath.
+func GetHooksDir(repoPath string) (string, error) {
+	stdout, _, err := runGitCommand(repoPath, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", fmt.Errorf("failed to find hooks directory: %w", err)
+	}
+	if !filepath.IsAbs(stdout) {
+		stdout = filepath.Join(repoPath, stdout)
+	}
+	return stdout, nil
+}
+
 // GetStagedDiff returns the diff of all staged changes.
 // An empty string is returned if there are no staged changes.
 func GetStagedDiff(repoPath string) (string, error) {
@@ -159,6 +307,20 @@ func GetStagedDiff(repoPath string) (string, error) {
 	return stdout, nil
 }
 
+// GetCurrentBranch returns the name of the checked out branch, or an empty
+// string if HEAD is detached.
+func GetCurrentBranch(repoPath string) (string, error) {
+	stdout, stderr, err := runGitCommand(repoPath, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		if stderr == "" {
+			// detached HEAD
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get current branch: %w", err)
+	}
+	return stdout, nil
+}
+
 // HasStagedChanges checks if there are any staged changes in the repository.
 func HasStagedChanges(repoPath string) (bool, error) {
 	// Use --quiet option which exits with 1 if there are differences and 0 if not
@@ -174,10 +336,42 @@ func HasStagedChanges(repoPath string) (bool, error) {
 	return false, nil
 }
 
-func Commit(msg string, repoPath string) error {
+// CommitOptions configures Commit.
+type CommitOptions struct {
+	// Trailers are appended to the message, see AddTrailers.
+	Trailers []Trailer
+	// Args are passed on to git commit, e.g. -S, --no-verify or --author.
+	Args []string
+}
+
+// Commit opens the editor on msg and commits the staged changes with the
+// result. The comment lines of the user's commit.template are shown around
+// the message, as git would show them without -F, and its trailers are kept.
+func Commit(msg string, repoPath string, opts CommitOptions) error {
+	commitArgs, argTrailers, err := takeTrailerArgs(repoPath, opts.Args)
+	if err != nil {
+		return err
+	}
+	template, err := GetCommitTemplate(repoPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	commentChar := GetCommentChar(repoPath)
+	if stripsComments(repoPath, commitArgs) {
+		msg = MergeTemplate(msg, template, commentChar)
+	}
+	// trailers are added to the final message, so one the message, the
+	// template or git commit's options already give isn't repeated
+	trailers := append(TemplateTrailers(template, commentChar), opts.Trailers...)
+	msg, err = AddTrailers(repoPath, msg, append(trailers, argTrailers...))
+	if err != nil {
+		return err
+	}
+
 	// Use the common runGitCommand helper to maintain consistency
 	// However, we need to handle stdin and interactive editor differently
-	cmd := exec.Command("git", "commit", "-eF", "-")
+	args := append([]string{"commit", "-eF", "-"}, commitArgs...)
+	cmd := exec.Command("git", args...)
 	if repoPath != "" {
 		cmd.Dir = repoPath
 	}
@@ -231,3 +425,67 @@ func Commit(msg string, repoPath string) error {
 
 	return nil
 }
+
+// takeTrailerArgs removes the options that make git commit add trailers, -s,
+// --signoff and --trailer, from args and returns the trailers they add.
+func takeTrailerArgs(repoPath string, args []string) ([]string, []Trailer, error) {
+	var rest []string
+	var trailers []Trailer
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var value string
+		switch {
+		case arg == "--":
+			// the rest are pathspecs
+			return append(rest, args[i:]...), trailers, nil
+		case arg == "-s" || arg == "--signoff":
+			ident, err := GetCommitterIdent(repoPath)
+			if err != nil {
+				return nil, nil, err
+			}
+			trailers = append(trailers, Trailer{Key: "Signed-off-by", Value: ident})
+			continue
+		case arg == "--trailer" && i+1 < len(args):
+			i++
+			value = args[i]
+		case strings.HasPrefix(arg, "--trailer="):
+			value = strings.TrimPrefix(arg, "--trailer=")
+		default:
+			rest = append(rest, arg)
+			continue
+		}
+		t, err := ParseTrailer(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		trailers = append(trailers, t)
+	}
+	return rest, trailers, nil
+}
+
+// stripsComments reports whether git commit, run with args, removes comment
+// lines from the edited message, so template comments can't end up in it.
+func stripsComments(repoPath string, args []string) bool {
+	cleanup, _, _ := runGitCommand(repoPath, "config", "commit.cleanup")
+	for i, arg := range args {
+		switch {
+		case arg == "--no-edit":
+			return false
+		case strings.HasPrefix(arg, "--cleanup="):
+			cleanup = strings.TrimPrefix(arg, "--cleanup=")
+		case arg == "--cleanup" && i+1 < len(args):
+			cleanup = args[i+1]
+		}
+	}
+	return cleanup == "" || cleanup == "default" || cleanup == "strip"
+}
+
+// GetEditor returns the editor git would use, honouring GIT_EDITOR, core.editor,
+// VISUAL and EDITOR in the same order as git itself.
+func GetEditor(repoPath string) (string, error) {
+	stdout, _, err := runGitCommand(repoPath, "var", "GIT_EDITOR")
+	if err != nil {
+		return "", fmt.Errorf("failed to determine editor: %w", err)
+	}
+	return stdout, nil
+}
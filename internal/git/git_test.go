@@ -0,0 +1,204 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLogFilterMatchSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  LogFilter
+		subject string
+		want    bool
+	}{
+		{"zero filter", LogFilter{}, "wip", true},
+		{"too short", LogFilter{MinSubjectLength: 10}, "fix typo", false},
+		{"long enough", LogFilter{MinSubjectLength: 10}, "fix typo in readme", true},
+		{"excluded", LogFilter{ExcludeMessage: regexp.MustCompile(`^wip|^fixup!`)}, "fixup! feat: add login", false},
+		{"not excluded", LogFilter{ExcludeMessage: regexp.MustCompile(`^wip|^fixup!`)}, "feat: add login", true},
+		{"conventional only", LogFilter{ConventionalOnly: true}, "Add login", false},
+		{"conventional", LogFilter{ConventionalOnly: true}, "feat: add login", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matchSubject(tt.subject); got != tt.want {
+				t.Errorf("matchSubject(%q) = %v, want %v", tt.subject, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCommitLog_Count(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Alice", "GIT_AUTHOR_EMAIL=alice@example.com",
+			"GIT_COMMITTER_NAME=Alice", "GIT_COMMITTER_EMAIL=alice@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	for i := 0; i < 12; i++ {
+		git("commit", "-q", "--allow-empty", "-m", fmt.Sprintf("commit %d", i))
+	}
+
+	commits, err := GetCommitLog(dir, "", 5, LogFilter{})
+	if err != nil || len(commits) != 5 || commits[0].Subject != "commit 11" {
+		t.Errorf("GetCommitLog() with a count = %+v, %v", commits, err)
+	}
+	// a count of 0 lists every commit in the range
+	commits, err = GetCommitLog(dir, "HEAD~11..HEAD", 0, LogFilter{})
+	if err != nil || len(commits) != 11 {
+		t.Errorf("GetCommitLog() of a range = %d commits, %v; want 11", len(commits), err)
+	}
+}
+
+func TestIsAncestor(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Alice", "GIT_AUTHOR_EMAIL=alice@example.com",
+			"GIT_COMMITTER_NAME=Alice", "GIT_COMMITTER_EMAIL=alice@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	git("commit", "-q", "--allow-empty", "-m", "first")
+	git("commit", "-q", "--allow-empty", "-m", "second")
+	second := git("rev-parse", "HEAD")
+	// rewriting history leaves the old commit out of it
+	git("commit", "-q", "--amend", "--allow-empty", "-m", "rewritten")
+
+	tests := []struct {
+		commit string
+		want   bool
+	}{
+		{git("rev-parse", "HEAD~1"), true},
+		{git("rev-parse", "HEAD"), true},
+		{second, false},
+		{strings.Repeat("1", 40), false}, // no longer exists
+	}
+	for _, tt := range tests {
+		if got := IsAncestor(dir, tt.commit, "HEAD"); got != tt.want {
+			t.Errorf("IsAncestor(%s, HEAD) = %v, want %v", tt.commit, got, tt.want)
+		}
+	}
+}
+
+func TestGetCommitPatch_GitInvocations(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("counts invocations with a shell script")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Alice", "GIT_AUTHOR_EMAIL=alice@example.com",
+			"GIT_COMMITTER_NAME=Alice", "GIT_COMMITTER_EMAIL=alice@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write("notes.txt", "a\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "init")
+	write("notes.txt", "b\n")
+	write("main.go", "package main\n\nfunc main() {\n}\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "feat: add main")
+	write("main.go", "package main\n\nfunc main() {\n\trun()\n}\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "fix: run")
+
+	// log every git command run through a wrapper found first on the path
+	realGit, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, logFile := t.TempDir(), filepath.Join(t.TempDir(), "git.log")
+	wrapper := fmt.Sprintf("#!/bin/sh\necho \"$1\" >> %q\nexec %q \"$@\"\n", logFile, realGit)
+	if err := os.WriteFile(filepath.Join(bin, "git"), []byte(wrapper), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tests := []struct {
+		rev  string
+		want string
+	}{
+		{"HEAD~2", "log"}, // the initial commit
+		{"HEAD~1", "log"}, // only added files, so no function context
+		{"HEAD", "log,diff"},
+	}
+	for _, tt := range tests {
+		os.Remove(logFile)
+		if _, _, err := GetCommitPatch(context.Background(), dir, tt.rev); err != nil {
+			t.Fatalf("GetCommitPatch(%s) failed: %v", tt.rev, err)
+		}
+		data, _ := os.ReadFile(logFile)
+		if got := strings.Join(strings.Fields(string(data)), ","); got != tt.want {
+			t.Errorf("GetCommitPatch(%s) ran git %s, want %s", tt.rev, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeDiff(t *testing.T) {
+	diff := `diff --git a/cmd/root.go b/cmd/root.go
+index 1111111..2222222 100644
+--- a/cmd/root.go
++++ b/cmd/root.go
+@@ -1,2 +1,4 @@
+ package cmd
+-import "fmt"
++import (
++	"fmt"
++)
+diff --git a/old name.txt b/new name.txt
+similarity index 100%
+rename from old name.txt
+rename to new name.txt
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+--- a/gone.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package gone
+---- not a header
+`
+	s := SummarizeDiff(diff)
+	wantFiles := []string{"cmd/root.go", "new name.txt", "gone.go"}
+	if strings.Join(s.Files, ",") != strings.Join(wantFiles, ",") {
+		t.Errorf("Files = %q, want %q", s.Files, wantFiles)
+	}
+	if s.Additions != 3 || s.Deletions != 3 {
+		t.Errorf("Additions, Deletions = %d, %d; want 3, 3", s.Additions, s.Deletions)
+	}
+}
@@ -0,0 +1,106 @@
+package git
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Prefix used for repository identities derived from the root commit.
+const rootCommitPrefix = "root:"
+
+// GetRepoIdentity returns a stable identifier for the repository at repoPath
+// that is shared by every clone, worktree or moved checkout of it.
+//
+// The normalized URL of the "origin" remote (or the first remote) is preferred,
+// falling back to the SHA of the root commit and finally to the absolute path.
+func GetRepoIdentity(repoPath string) (string, error) {
+	if remoteURL := getRemoteURL(repoPath); remoteURL != "" {
+		if id := NormalizeRemoteURL(remoteURL); id != "" {
+			return id, nil
+		}
+	}
+
+	if sha := getRootCommit(repoPath); sha != "" {
+		return rootCommitPrefix + sha, nil
+	}
+
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for %s: %w", repoPath, err)
+	}
+	return absPath, nil
+}
+
+func getRemoteURL(repoPath string) string {
+	stdout, _, err := runGitCommand(repoPath, "remote")
+	if err != nil || stdout == "" {
+		return ""
+	}
+	remotes := strings.Split(stdout, "\n")
+	remote := remotes[0]
+	for _, r := range remotes {
+		if r == "origin" {
+			remote = r
+			break
+		}
+	}
+	remoteURL, _, err := runGitCommand(repoPath, "remote", "get-url", remote)
+	if err != nil {
+		return ""
+	}
+	return remoteURL
+}
+
+// getRootCommit returns the root commit of HEAD, choosing the smallest SHA
+// when the history has several roots so the result is deterministic.
+func getRootCommit(repoPath string) string {
+	stdout, _, err := runGitCommand(repoPath, "rev-list", "--max-parents=0", "HEAD")
+	if err != nil || stdout == "" {
+		return ""
+	}
+	roots := strings.Split(stdout, "\n")
+	sort.Strings(roots)
+	return roots[0]
+}
+
+// NormalizeRemoteURL reduces the different spellings of a remote URL to a
+// canonical "host/owner/repo" form, so that e.g.
+// "git@github.com:Owner/Repo.git" and "https://github.com/Owner/Repo"
+// both become "github.com/owner/repo".
+//
+// An empty string is returned for local remotes, which do not identify a
+// repository across machines.
+func NormalizeRemoteURL(remoteURL string) string {
+	raw := strings.TrimSpace(remoteURL)
+	if raw == "" {
+		return ""
+	}
+
+	var host, path string
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme == "file" || u.Host == "" {
+			return ""
+		}
+		host, path = u.Hostname(), u.Path
+	} else if i := strings.Index(raw, ":"); i > 1 && !strings.Contains(raw[:i], "/") {
+		// scp-like syntax: [user@]host:path (i > 1 skips windows drive letters)
+		host, path = raw[:i], raw[i+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	} else {
+		// local path
+		return ""
+	}
+
+	path = strings.Trim(path, "/")
+	path = strings.TrimSuffix(path, ".git")
+	if host == "" || path == "" {
+		return ""
+	}
+	return strings.ToLower(host + "/" + path)
+}
@@ -0,0 +1,332 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	// functionContextMaxChanges is the most changed lines a file may have
+	// for its diff to show the whole of each function it touches.
+	functionContextMaxChanges = 20
+	// functionContextMaxLines caps the size of a file's diff with function
+	// context, beyond which the plain diff is used.
+	functionContextMaxLines = 150
+)
+
+// functionContextLanguages are the languages git finds function boundaries
+// in well enough for --function-context to help.
+var functionContextLanguages = map[string]bool{
+	"Go": true, "Python": true, "JavaScript": true, "TypeScript": true, "Java": true,
+	"Kotlin": true, "Scala": true, "C": true, "C++": true, "C#": true, "Ruby": true,
+	"Rust": true, "PHP": true, "Swift": true, "Shell": true,
+}
+
+// FileStat is a file in a diff with its status and line counts.
+type FileStat struct {
+	// Status is git's status letter: A, M, D, R or C.
+	Status string
+	Path   string
+	// OldPath is the path a renamed or copied file came from.
+	OldPath   string
+	Additions int
+	Deletions int
+	Binary    bool
+}
+
+// GetStagedChanges returns the staged changes prepared for the llm: a
+// summary of the files changed followed by their diff. Renames are detected,
+// small changes to source files are shown with the whole function they are
+// in, and pure renames, mode changes and binary files are described in a
+// line instead of being diffed.
+func GetStagedChanges(repoPath string) (string, error) {
+	return getChanges(context.Background(), repoPath, "--staged")
+}
+
+// GetCommitChanges returns the changes a commit made, diffed against its
+// first parent (or the empty tree for the initial commit) and prepared like
+// GetStagedChanges, so learned examples look like what is generated from.
+func GetCommitChanges(ctx context.Context, repoPath, sha string) (string, error) {
+	_, changes, err := GetCommitPatch(ctx, repoPath, sha)
+	return changes, err
+}
+
+// getChanges prepares the diff git diff gives for revs.
+func getChanges(ctx context.Context, repoPath string, revs ...string) (string, error) {
+	diffArgs := append([]string{"diff", "--find-renames", "--full-index"}, revs...)
+	diff, _, err := runGitCommandContext(ctx, repoPath, diffArgs...)
+	if err != nil {
+		return "", fmt.Errorf("failed to get changes: %w", err)
+	}
+	return prepareChanges(ctx, repoPath, diff, revs...)
+}
+
+// prepareChanges prepares diff, the output of git diff --find-renames
+// --full-index for revs. Git is only run again for the files shown with
+// their whole functions and to describe binary files.
+func prepareChanges(ctx context.Context, repoPath, diff string, revs ...string) (string, error) {
+	parsed, err := ParseDiff(diff)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse changes: %w", err)
+	}
+	stats := fileStats(parsed)
+	if len(stats) == 0 {
+		return "", nil
+	}
+	chunks := splitDiff(diff)
+
+	// show the enclosing functions of small changes, keeping the plain diff
+	// of any file where that turns out to be too much
+	if paths := functionContextPaths(stats); len(paths) > 0 {
+		args := append([]string{"diff", "--find-renames", "--full-index"}, revs...)
+		args = append(append(args, "--function-context", "--"), paths...)
+		withContext, _, err := runGitCommandContext(ctx, repoPath, args...)
+		if err != nil {
+			return "", fmt.Errorf("failed to get changes: %w", err)
+		}
+		byHeader := map[string]string{}
+		for _, chunk := range splitDiff(withContext) {
+			if strings.Count(chunk, "\n") < functionContextMaxLines {
+				header, _, _ := strings.Cut(chunk, "\n")
+				byHeader[header] = chunk
+			}
+		}
+		for i, chunk := range chunks {
+			header, _, _ := strings.Cut(chunk, "\n")
+			if c, ok := byHeader[header]; ok {
+				chunks[i] = c
+			}
+		}
+	}
+
+	describe := func(oid string) string { return describeBlob(repoPath, oid) }
+	var b strings.Builder
+	b.WriteString(FormatFileStats(stats))
+	for _, chunk := range chunks {
+		b.WriteString("\n" + prepareChunk(chunk, describe))
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// fileStats lists the files a diff changes with their line counts.
+func fileStats(d *Diff) []FileStat {
+	stats := make([]FileStat, 0, len(d.Files))
+	for _, f := range d.Files {
+		s := FileStat{
+			Status:    string(f.Status),
+			Path:      f.Path(),
+			Additions: f.Additions(),
+			Deletions: f.Deletions(),
+			Binary:    f.Binary,
+		}
+		if f.Status == StatusRenamed || f.Status == StatusCopied {
+			s.OldPath = f.OldPath
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// FormatFileStats summarizes the changed files, one per line with their
+// status and line counts, followed by the totals.
+func FormatFileStats(stats []FileStat) string {
+	var b strings.Builder
+	additions, deletions := 0, 0
+	for _, s := range stats {
+		path := s.Path
+		if s.OldPath != "" {
+			path = s.OldPath + " => " + s.Path
+		}
+		switch {
+		case s.Binary:
+			fmt.Fprintf(&b, "%s  %s (binary)\n", s.Status, path)
+		case s.Additions == 0 && s.Deletions == 0:
+			fmt.Fprintf(&b, "%s  %s\n", s.Status, path)
+		default:
+			fmt.Fprintf(&b, "%s  %s (+%d -%d)\n", s.Status, path, s.Additions, s.Deletions)
+		}
+		additions += s.Additions
+		deletions += s.Deletions
+	}
+	fmt.Fprintf(&b, "%d %s changed, %d %s(+), %d %s(-)\n",
+		len(stats), plural(len(stats), "file", "files"),
+		additions, plural(additions, "insertion", "insertions"),
+		deletions, plural(deletions, "deletion", "deletions"))
+	return b.String()
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// functionContextPaths returns the pathspecs of the files small enough to be
+// diffed with --function-context.
+func functionContextPaths(stats []FileStat) []string {
+	var paths []string
+	for _, s := range stats {
+		changes := s.Additions + s.Deletions
+		if s.Binary || changes == 0 || changes > functionContextMaxChanges ||
+			(s.Status != "M" && s.Status != "R") || !functionContextLanguages[Language(s.Path)] {
+			continue
+		}
+		// a rename is only detected when both paths are diffed
+		if s.OldPath != "" {
+			paths = append(paths, ":(literal)"+s.OldPath)
+		}
+		paths = append(paths, ":(literal)"+s.Path)
+	}
+	return paths
+}
+
+// splitDiff splits the output of git diff into one chunk per file.
+func splitDiff(diff string) []string {
+	var chunks []string
+	start := -1
+	for i := 0; i < len(diff); {
+		if strings.HasPrefix(diff[i:], "diff --git ") {
+			if start >= 0 {
+				chunks = append(chunks, diff[start:i])
+			}
+			start = i
+		}
+		next := strings.IndexByte(diff[i:], '\n')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	if start >= 0 {
+		chunks = append(chunks, strings.TrimRight(diff[start:], "\n")+"\n")
+	}
+	return chunks
+}
+
+// prepareChunk tidies the diff of one file: index lines are dropped, a file
+// that was only renamed or had its mode changed is described in one line and
+// a binary file is described by describe, which is given the blob's id.
+func prepareChunk(chunk string, describe func(oid string) string) string {
+	lines := strings.Split(strings.TrimRight(chunk, "\n"), "\n")
+	var oldOID, newOID, oldMode, newMode, renameFrom, renameTo string
+	hasHunks, binary := false, false
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			hasHunks = true
+		case hasHunks:
+			// the rest is content
+		case strings.HasPrefix(line, "index "):
+			ids, _, _ := strings.Cut(strings.TrimPrefix(line, "index "), " ")
+			oldOID, newOID, _ = strings.Cut(ids, "..")
+		case strings.HasPrefix(line, "old mode "):
+			oldMode = strings.TrimPrefix(line, "old mode ")
+		case strings.HasPrefix(line, "new mode "):
+			newMode = strings.TrimPrefix(line, "new mode ")
+		case strings.HasPrefix(line, "rename from "):
+			renameFrom = strings.TrimPrefix(line, "rename from ")
+		case strings.HasPrefix(line, "rename to "):
+			renameTo = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+			binary = true
+		}
+	}
+
+	switch {
+	case !hasHunks && !binary && renameTo != "":
+		line := "rename " + renameFrom + " => " + renameTo + " (no content changes)"
+		if oldMode != "" {
+			line += ", mode change " + oldMode + " => " + newMode
+		}
+		return line + "\n"
+	case !hasHunks && !binary && oldMode != "":
+		_, path := parseGitHeaderPaths(strings.TrimPrefix(lines[0], "diff --git "))
+		return "mode change " + oldMode + " => " + newMode + " " + path + "\n"
+	}
+
+	var b strings.Builder
+	inHunks := false
+	for _, line := range lines {
+		inHunks = inHunks || strings.HasPrefix(line, "@@")
+		switch {
+		case inHunks:
+			b.WriteString(line + "\n")
+		case strings.HasPrefix(line, "index "):
+			// the blob ids mean nothing to the llm
+		case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+			b.WriteString(describeBinaryChange(oldOID, newOID, describe) + "\n")
+			// a binary patch's data follows
+			return b.String()
+		default:
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// describeBinaryChange describes a binary file being added, deleted or
+// changed from the descriptions of its old and new blobs.
+func describeBinaryChange(oldOID, newOID string, describe func(oid string) string) string {
+	isNull := func(oid string) bool { return strings.Trim(oid, "0") == "" }
+	switch {
+	case isNull(oldOID) && isNull(newOID):
+		return "Binary file changed"
+	case isNull(oldOID):
+		return "Binary file added: " + describe(newOID)
+	case isNull(newOID):
+		return "Binary file deleted: " + describe(oldOID)
+	default:
+		return "Binary file changed: " + describe(oldOID) + " => " + describe(newOID)
+	}
+}
+
+// describeBlob describes a blob by its content type and size, e.g.
+// "image/png, 12.3 KiB".
+func describeBlob(repoPath, oid string) string {
+	size, _, err := runGitCommand(repoPath, "cat-file", "-s", oid)
+	if err != nil {
+		return "unknown"
+	}
+	n, _ := strconv.ParseInt(size, 10, 64)
+	return blobType(repoPath, oid) + ", " + formatSize(n)
+}
+
+// blobType sniffs the content type of a blob from its first bytes.
+func blobType(repoPath, oid string) string {
+	cmd := exec.Command("git", "cat-file", "blob", oid)
+	cmd.Dir = repoPath
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return "application/octet-stream"
+	}
+	if err := cmd.Start(); err != nil {
+		return "application/octet-stream"
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(out, head)
+	// the rest of the blob isn't needed
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	return contentType
+}
+
+// formatSize formats a size in bytes for people to read.
+func formatSize(n int64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KiB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1024*1024))
+	}
+}
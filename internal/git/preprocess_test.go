@@ -0,0 +1,217 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileStats(t *testing.T) {
+	d, err := ParseDiff("diff --git a/main.go b/main.go\nindex 1111..2222 100644\n--- a/main.go\n+++ b/main.go\n" +
+		"@@ -1,2 +1,4 @@\n-a\n+b\n+c\n+d\n x\n" +
+		"diff --git a/old name.txt b/new name.txt\nsimilarity index 100%\nrename from old name.txt\nrename to new name.txt\n" +
+		"diff --git a/logo.png b/logo.png\nnew file mode 100644\nindex 0000..3333\nBinary files /dev/null and b/logo.png differ\n")
+	if err != nil {
+		t.Fatalf("ParseDiff() failed: %v", err)
+	}
+	stats := fileStats(d)
+
+	want := []FileStat{
+		{Status: "M", Path: "main.go", Additions: 3, Deletions: 1},
+		{Status: "R", Path: "new name.txt", OldPath: "old name.txt"},
+		{Status: "A", Path: "logo.png", Binary: true},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("got %d files, want %d: %+v", len(stats), len(want), stats)
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Errorf("file %d = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+
+	got := FormatFileStats(stats)
+	wantSummary := "M  main.go (+3 -1)\nR  old name.txt => new name.txt\nA  logo.png (binary)\n" +
+		"3 files changed, 3 insertions(+), 1 deletion(-)\n"
+	if got != wantSummary {
+		t.Errorf("FormatFileStats() = %q, want %q", got, wantSummary)
+	}
+}
+
+func TestPrepareChunk(t *testing.T) {
+	describe := func(oid string) string { return "blob " + oid[:2] }
+	tests := []struct {
+		name  string
+		chunk string
+		want  string
+	}{
+		{
+			"index line dropped",
+			"diff --git a/main.go b/main.go\nindex 1111..2222 100644\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-index a\n+index b\n",
+			"diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-index a\n+index b\n",
+		},
+		{
+			"pure rename",
+			"diff --git a/old.txt b/new.txt\nsimilarity index 100%\nrename from old.txt\nrename to new.txt\n",
+			"rename old.txt => new.txt (no content changes)\n",
+		},
+		{
+			"rename with mode change",
+			"diff --git a/a.sh b/b.sh\nold mode 100644\nnew mode 100755\nsimilarity index 100%\nrename from a.sh\nrename to b.sh\n",
+			"rename a.sh => b.sh (no content changes), mode change 100644 => 100755\n",
+		},
+		{
+			"mode change",
+			"diff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n",
+			"mode change 100644 => 100755 run.sh\n",
+		},
+		{
+			"mode change with edits",
+			"diff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\nindex 1111..2222\n--- a/run.sh\n+++ b/run.sh\n@@ -1 +1 @@\n-a\n+b\n",
+			"diff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n--- a/run.sh\n+++ b/run.sh\n@@ -1 +1 @@\n-a\n+b\n",
+		},
+		{
+			"binary added",
+			"diff --git a/logo.png b/logo.png\nnew file mode 100644\nindex 0000000..abcdef0\nBinary files /dev/null and b/logo.png differ\n",
+			"diff --git a/logo.png b/logo.png\nnew file mode 100644\nBinary file added: blob ab\n",
+		},
+		{
+			"binary deleted",
+			"diff --git a/logo.png b/logo.png\ndeleted file mode 100644\nindex abcdef0..0000000\nBinary files a/logo.png and /dev/null differ\n",
+			"diff --git a/logo.png b/logo.png\ndeleted file mode 100644\nBinary file deleted: blob ab\n",
+		},
+		{
+			"binary changed",
+			"diff --git a/logo.png b/logo.png\nindex 1234567..abcdef0 100644\nBinary files a/logo.png and b/logo.png differ\n",
+			"diff --git a/logo.png b/logo.png\nBinary file changed: blob 12 => blob ab\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prepareChunk(tt.chunk, describe); got != tt.want {
+				t.Errorf("prepareChunk() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitDiff(t *testing.T) {
+	diff := "diff --git a/a b/a\n@@ -1 +1 @@\n-diff --git a/x b/x\n+a\ndiff --git a/b b/b\n@@ -1 +1 @@\n-b\n+c"
+	chunks := splitDiff(diff)
+	want := []string{
+		"diff --git a/a b/a\n@@ -1 +1 @@\n-diff --git a/x b/x\n+a\n",
+		"diff --git a/b b/b\n@@ -1 +1 @@\n-b\n+c\n",
+	}
+	if strings.Join(chunks, "|") != strings.Join(want, "|") {
+		t.Errorf("splitDiff() = %q, want %q", chunks, want)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 3 << 20: "3.0 MiB"} {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestGetStagedChanges(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Alice", "GIT_AUTHOR_EMAIL=alice@example.com",
+			"GIT_COMMITTER_NAME=Alice", "GIT_COMMITTER_EMAIL=alice@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	function := func(first string) string {
+		lines := []string{"package main", "", "func run() {", "\t" + first}
+		for i := 0; i < 10; i++ {
+			lines = append(lines, "\tstep()")
+		}
+		return strings.Join(append(lines, "\tdone()", "}", ""), "\n")
+	}
+
+	git("init", "-q")
+	write("main.go", function("start()"))
+	write("old.txt", "unchanged\n")
+	write("logo.png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	git("add", "-A")
+	git("commit", "-q", "-m", "init")
+
+	write("main.go", function("begin()"))
+	write("logo.png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00")
+	git("mv", "old.txt", "new.txt")
+	git("add", "-A")
+
+	got, err := GetStagedChanges(dir)
+	if err != nil {
+		t.Fatalf("GetStagedChanges() failed: %v", err)
+	}
+	for _, want := range []string{
+		"M  main.go (+1 -1)\n",
+		"3 files changed, 1 insertion(+), 1 deletion(-)\n",
+		// the whole function is shown, not just three lines of context
+		"\tdone()\n }",
+		"Binary file changed: image/png, 16 B => image/png, 18 B",
+		"rename old.txt => new.txt (no content changes)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "index ") {
+		t.Errorf("Expected index lines to be dropped:\n%s", got)
+	}
+
+	s := SummarizeDiff(got)
+	if strings.Join(s.Files, ",") != "logo.png,main.go,new.txt" || s.Additions != 1 || s.Deletions != 1 {
+		t.Errorf("SummarizeDiff() = %+v", s)
+	}
+
+	d, err := ParseDiff(got)
+	if err != nil {
+		t.Fatalf("ParseDiff() failed: %v", err)
+	}
+	wantFiles := []file{
+		{Status: StatusModified, OldPath: "logo.png", NewPath: "logo.png", Binary: true},
+		{Status: StatusModified, OldPath: "main.go", NewPath: "main.go", Language: "Go",
+			Hunks: 1, Additions: 1, Deletions: 1},
+		{Status: StatusRenamed, OldPath: "old.txt", NewPath: "new.txt", Similarity: 100},
+	}
+	if len(d.Files) != len(wantFiles) {
+		t.Fatalf("ParseDiff() found %d files, want %d", len(d.Files), len(wantFiles))
+	}
+	for i, want := range wantFiles {
+		if got := summarize(d.Files[i]); got != want {
+			t.Errorf("file %d = %+v\nwant %+v", i, got, want)
+		}
+	}
+
+	// a commit's changes are prepared the same way
+	git("commit", "-q", "-m", "edit")
+	committed, err := GetCommitChanges(context.Background(), dir, "HEAD")
+	if err != nil {
+		t.Fatalf("GetCommitChanges() failed: %v", err)
+	}
+	if committed != got {
+		t.Errorf("GetCommitChanges() = %q, want the staged changes %q", committed, got)
+	}
+	initial, err := GetCommitChanges(context.Background(), dir, "HEAD~1")
+	if err != nil || !strings.HasPrefix(initial, "A  logo.png (binary)\nA  main.go (+16 -0)\nA  old.txt (+1 -0)\n") {
+		t.Errorf("GetCommitChanges() of the initial commit = %q, %v", initial, err)
+	}
+}
@@ -0,0 +1,129 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// GetCommitTemplate returns the contents of the file configured as
+// commit.template, or an empty string when none is set.
+func GetCommitTemplate(repoPath string) (string, error) {
+	path, stderr, err := runGitCommand(repoPath, "config", "--path", "commit.template")
+	if err != nil {
+		if stderr == "" {
+			// not set
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read commit.template: %w", err)
+	}
+	if path == "" {
+		return "", nil
+	}
+	// git resolves a relative template path against the working directory
+	if !filepath.IsAbs(path) && repoPath != "" {
+		path = filepath.Join(repoPath, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read commit template: %w", err)
+	}
+	return string(data), nil
+}
+
+// GetCommentChar returns the character git uses to start comment lines in
+// commit messages, honouring core.commentChar.
+func GetCommentChar(repoPath string) string {
+	stdout, _, err := runGitCommand(repoPath, "config", "core.commentChar")
+	if err != nil || stdout == "" || stdout == "auto" {
+		return "#"
+	}
+	return stdout
+}
+
+// MergeTemplate places the comment lines of a commit template around msg so
+// the guidance they give is still shown in the editor. Comments that come
+// before the template's own text go above the message and the rest below it;
+// the template's text itself is replaced by msg, apart from its trailers (see
+// TemplateTrailers). A template made only of comments goes below the message,
+// where git would have put it.
+func MergeTemplate(msg, template, commentChar string) string {
+	var above, below []string
+	seenText := false
+	for _, line := range strings.Split(strings.TrimRight(template, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, commentChar):
+			if seenText {
+				below = append(below, line)
+			} else {
+				above = append(above, line)
+			}
+		case strings.TrimSpace(line) != "":
+			seenText = true
+		}
+	}
+	if !seenText {
+		above, below = nil, above
+	}
+	if len(above) == 0 && len(below) == 0 {
+		return msg
+	}
+
+	var b strings.Builder
+	for _, line := range above {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString(strings.TrimRight(msg, "\n") + "\n")
+	if len(below) > 0 {
+		b.WriteString("\n")
+		for _, line := range below {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// templateTrailerPattern matches a trailer in a template, whose value may be
+// left empty for the user to fill in, as in "Reviewed-by: ".
+var templateTrailerPattern = regexp.MustCompile(`^([A-Za-z][\w-]*)[ \t]*:[ \t]*(.*)$`)
+
+// TemplateTrailers returns the trailers that end the text of a commit
+// template, such as "Signed-off-by:" or "Reviewed-by:" placeholders, so they
+// can be kept in the generated message.
+func TemplateTrailers(template, commentChar string) []Trailer {
+	var paragraphs [][]string
+	blank := true
+	leadingBlank := false
+	for _, line := range strings.Split(template, "\n") {
+		switch {
+		case strings.HasPrefix(line, commentChar):
+		case strings.TrimSpace(line) == "":
+			blank = true
+			leadingBlank = leadingBlank || len(paragraphs) == 0
+		default:
+			if blank {
+				paragraphs = append(paragraphs, nil)
+				blank = false
+			}
+			last := len(paragraphs) - 1
+			paragraphs[last] = append(paragraphs[last], strings.TrimRight(line, " \t"))
+		}
+	}
+	// as in git, the first paragraph is the subject unless the template
+	// leaves room for one
+	if len(paragraphs) == 0 || len(paragraphs) == 1 && !leadingBlank {
+		return nil
+	}
+
+	var trailers []Trailer
+	for _, line := range paragraphs[len(paragraphs)-1] {
+		m := templateTrailerPattern.FindStringSubmatch(line)
+		if m == nil {
+			return nil
+		}
+		trailers = append(trailers, Trailer{Key: m[1], Value: m[2]})
+	}
+	return trailers
+}
@@ -0,0 +1,93 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeTemplate(t *testing.T) {
+	msg := "feat: add login\n\n- add form\n"
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"no template", "", msg},
+		{"no comments", "Subject\n\nBody\n", msg},
+		{
+			"only comments",
+			"# <type>: <subject>\n#\n# Explain why.\n",
+			"feat: add login\n\n- add form\n\n# <type>: <subject>\n#\n# Explain why.\n",
+		},
+		{
+			"comments around text",
+			"# Keep it short\nSubject\n\n# Why was this needed?\n# Refs: PROJ-\n",
+			"# Keep it short\nfeat: add login\n\n- add form\n\n# Why was this needed?\n# Refs: PROJ-\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeTemplate(msg, tt.template, "#"); got != tt.want {
+				t.Errorf("MergeTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := MergeTemplate("fix: typo", "; guidance\n# not a comment\n", ";"); got != "; guidance\nfix: typo\n" {
+		t.Errorf("MergeTemplate() with ';' = %q", got)
+	}
+}
+
+func TestTemplateTrailers(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     []Trailer
+	}{
+		{"no template", "", nil},
+		{"only comments", "# Signed-off-by: \n", nil},
+		{"no trailers", "Subject\n\nBody\n", nil},
+		{
+			"placeholders",
+			"Subject\n\n# Who reviewed this?\nReviewed-by: \nSigned-off-by: Alice <alice@example.com>\n# end\n",
+			[]Trailer{{Key: "Reviewed-by"}, {Key: "Signed-off-by", Value: "Alice <alice@example.com>"}},
+		},
+		{"only trailers", "\n\nRefs:\n", []Trailer{{Key: "Refs"}}},
+		{"subject", "feat: \n", nil},
+		{"last paragraph isn't trailers", "Refs: PROJ-\n\nExplain why.\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TemplateTrailers(tt.template, "#")
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("TemplateTrailers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCommitTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, out)
+	}
+
+	got, err := GetCommitTemplate(dir)
+	if err != nil || got != "" {
+		t.Errorf("GetCommitTemplate() without a template = %q, %v", got, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "template.txt"), []byte("# guidance\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "-C", dir, "config", "commit.template", "template.txt").CombinedOutput(); err != nil {
+		t.Fatalf("git config failed: %v\n%s", err, out)
+	}
+	got, err = GetCommitTemplate(dir)
+	if err != nil || got != "# guidance\n" {
+		t.Errorf("GetCommitTemplate() = %q, %v", got, err)
+	}
+}
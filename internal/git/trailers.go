@@ -0,0 +1,107 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Trailer is a "Key: value" line at the end of a commit message, such as
+// "Signed-off-by: Name <email>".
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+func (t Trailer) String() string {
+	return t.Key + ": " + t.Value
+}
+
+// ParseTrailer parses a trailer written as "Key: value" or "Key=value", as
+// `git commit --trailer` accepts.
+func ParseTrailer(s string) (Trailer, error) {
+	i := strings.IndexAny(s, ":=")
+	if i <= 0 {
+		return Trailer{}, fmt.Errorf("invalid trailer '%s' (expected 'Key: value')", s)
+	}
+	key, value := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	if key == "" || value == "" || strings.ContainsAny(key, " \t") {
+		return Trailer{}, fmt.Errorf("invalid trailer '%s' (expected 'Key: value')", s)
+	}
+	return Trailer{Key: key, Value: value}, nil
+}
+
+// AddTrailers appends trailers to msg with `git interpret-trailers`, so they
+// join any trailer block the message already has and a trailer that is
+// already present isn't added again.
+func AddTrailers(repoPath, msg string, trailers []Trailer) (string, error) {
+	if len(trailers) == 0 {
+		return msg, nil
+	}
+	args := []string{"interpret-trailers", "--if-exists", "addIfDifferent", "--no-divider"}
+	for _, t := range trailers {
+		args = append(args, "--trailer", t.String())
+	}
+	cmd := exec.Command("git", args...)
+	if repoPath != "" {
+		cmd.Dir = repoPath
+	}
+	// without a final newline git takes a lone subject such as "fix: typo"
+	// for a trailer
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	cmd.Stdin = strings.NewReader(msg)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("failed to add trailers: %w\n%s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("failed to add trailers: %w", err)
+	}
+	return string(out), nil
+}
+
+// GetCommitterIdent returns the current user as "Name <email>", the form
+// Signed-off-by trailers use.
+func GetCommitterIdent(repoPath string) (string, error) {
+	stdout, _, err := runGitCommand(repoPath, "var", "GIT_COMMITTER_IDENT")
+	if err != nil {
+		return "", fmt.Errorf("failed to determine committer: %w", err)
+	}
+	// the ident ends with a timestamp and timezone
+	if i := strings.LastIndex(stdout, ">"); i >= 0 {
+		stdout = stdout[:i+1]
+	}
+	return stdout, nil
+}
+
+// GetRecentCoAuthors returns the Co-authored-by trailers of the current
+// user's commits since the given time (e.g. "12 hours ago"), most recent
+// first, so a pairing session can keep crediting the same people.
+func GetRecentCoAuthors(repoPath, since string) ([]string, error) {
+	email, _, err := runGitCommand(repoPath, "config", "user.email")
+	if err != nil || email == "" {
+		// without an identity there is no history of our own to look at
+		return nil, nil
+	}
+	stdout, _, err := runGitCommand(repoPath, "log", "--since="+since, "--author=<"+email+">",
+		"--format=format:%(trailers:key=Co-authored-by,valueonly,separator=%x00)%x1e")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recent co-authors: %w", err)
+	}
+
+	seen := map[string]bool{}
+	var coAuthors []string
+	for _, record := range strings.Split(stdout, "\x1e") {
+		for _, value := range strings.Split(record, "\x00") {
+			value = strings.TrimSpace(value)
+			if value == "" || seen[value] || strings.Contains(value, "<"+email+">") {
+				continue
+			}
+			seen[value] = true
+			coAuthors = append(coAuthors, value)
+		}
+	}
+	return coAuthors, nil
+}
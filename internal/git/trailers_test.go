@@ -0,0 +1,121 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseTrailer(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Trailer
+		wantErr bool
+	}{
+		{"Reviewed-by: Alice <alice@example.com>", Trailer{"Reviewed-by", "Alice <alice@example.com>"}, false},
+		{"Change-type=feature", Trailer{"Change-type", "feature"}, false},
+		{"Refs:  PROJ-1 ", Trailer{"Refs", "PROJ-1"}, false},
+		{"no separator", Trailer{}, true},
+		{": value", Trailer{}, true},
+		{"Key:", Trailer{}, true},
+		{"Two words: value", Trailer{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTrailer(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTrailer(%q) = %+v, %v", tt.input, got, err)
+		}
+	}
+}
+
+func TestAddTrailers(t *testing.T) {
+	msg := "feat: add login\n\n- add form\n---\nnot a divider\n\nSigned-off-by: Alice <alice@example.com>\n"
+	got, err := AddTrailers(t.TempDir(), msg, []Trailer{
+		{"Signed-off-by", "Alice <alice@example.com>"},
+		{"Co-authored-by", "Bob <bob@example.com>"},
+	})
+	if err != nil {
+		t.Fatalf("AddTrailers() failed: %v", err)
+	}
+	want := "feat: add login\n\n- add form\n---\nnot a divider\n\n" +
+		"Signed-off-by: Alice <alice@example.com>\nCo-authored-by: Bob <bob@example.com>\n"
+	if got != want {
+		t.Errorf("AddTrailers() = %q, want %q", got, want)
+	}
+
+	got, err = AddTrailers("", "fix: typo", []Trailer{{"Refs", "PROJ-1"}})
+	if err != nil {
+		t.Fatalf("AddTrailers() failed: %v", err)
+	}
+	if want := "fix: typo\n\nRefs: PROJ-1\n"; got != want {
+		t.Errorf("AddTrailers() = %q, want %q", got, want)
+	}
+}
+
+func TestGetRecentCoAuthors(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("config", "user.name", "Alice")
+	git("config", "user.email", "alice@example.com")
+	git("commit", "-q", "--allow-empty", "-m", "feat: one\n\nCo-authored-by: Bob <bob@example.com>")
+	git("commit", "-q", "--allow-empty", "-m", "feat: two\n\nCo-authored-by: Carol <carol@example.com>\n"+
+		"Co-authored-by: Bob <bob@example.com>")
+	git("-c", "user.email=dave@example.com", "commit", "-q", "--allow-empty", "-m",
+		"feat: three\n\nCo-authored-by: Erin <erin@example.com>\nCo-authored-by: Alice <alice@example.com>")
+
+	got, err := GetRecentCoAuthors(dir, "1 hour ago")
+	if err != nil {
+		t.Fatalf("GetRecentCoAuthors() failed: %v", err)
+	}
+	want := []string{"Carol <carol@example.com>", "Bob <bob@example.com>"}
+	if !slices.Equal(got, want) {
+		t.Errorf("GetRecentCoAuthors() = %v, want %v", got, want)
+	}
+}
+
+func TestCommit_Trailers(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+	git("init", "-q")
+	git("config", "user.name", "Alice")
+	git("config", "user.email", "alice@example.com")
+	template := "\n\n# Who reviewed this?\nReviewed-by: Bob <bob@example.com>\nSigned-off-by: Alice <alice@example.com>\n"
+	if err := os.WriteFile(filepath.Join(dir, "template.txt"), []byte(template), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("config", "commit.template", "template.txt")
+	// accept the message as it is given to the editor
+	t.Setenv("GIT_EDITOR", "true")
+
+	err := Commit("feat: add login\n\nRefs: PROJ-1\n", dir, CommitOptions{
+		Trailers: []Trailer{{"Signed-off-by", "Alice <alice@example.com>"}},
+		Args:     []string{"--allow-empty", "-s", "--trailer", "Reviewed-by: Bob <bob@example.com>"},
+	})
+	if err != nil {
+		t.Fatalf("Commit() failed: %v", err)
+	}
+	want := "feat: add login\n\nRefs: PROJ-1\nReviewed-by: Bob <bob@example.com>\nSigned-off-by: Alice <alice@example.com>\n"
+	if got := git("log", "-1", "--format=%B"); strings.TrimSpace(got) != strings.TrimSpace(want) {
+		t.Errorf("Committed message = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,202 @@
+// Package lint checks commit messages against a repository's rules, so the
+// same rules apply to generated and hand-written messages.
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kabilan108/diffgpt/internal/convention"
+)
+
+// Rules configures the checks. Zero values disable a check.
+type Rules struct {
+	// Convention is the form subjects must take, and the types they may use.
+	Convention       *convention.Convention
+	MaxSubjectLength int
+	BodyWidth        int
+	// Imperative flags subjects that don't start with an imperative verb.
+	Imperative bool
+	// Scopes are the only scopes subjects may use.
+	Scopes []string
+	// TicketPattern matches ticket IDs, which may prefix the subject as in
+	// "[PROJ-123] feat: add login". With RequireTicket, every message must
+	// mention one.
+	TicketPattern *regexp.Regexp
+	RequireTicket bool
+}
+
+// DefaultRules are the rules used unless a repository configures others.
+func DefaultRules(conv *convention.Convention) Rules {
+	return Rules{
+		Convention:       conv,
+		MaxSubjectLength: 72,
+		BodyWidth:        convention.BodyWidth,
+		Imperative:       true,
+	}
+}
+
+// WithTicket returns rules that also accept ticket, such as the ticket of
+// the current branch, as a subject prefix. Messages it was added to pass even
+// when TicketPattern is unset or doesn't match it.
+func (r Rules) WithTicket(ticket string) Rules {
+	if ticket == "" || r.TicketPattern != nil && r.TicketPattern.MatchString(ticket) {
+		return r
+	}
+	source := "^" + regexp.QuoteMeta(ticket) + "$"
+	if r.TicketPattern != nil {
+		source = "(?:" + r.TicketPattern.String() + ")|" + source
+	}
+	r.TicketPattern = regexp.MustCompile(source)
+	return r
+}
+
+// Problem is a rule a message breaks.
+type Problem struct {
+	Rule string
+	// Line is the 1-based line of the message the problem is on.
+	Line    int
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("line %d: %s (%s)", p.Line, p.Message, p.Rule)
+}
+
+// Error combines problems into a single error, or returns nil if there are
+// none.
+func Error(problems []Problem) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	lines := make([]string, len(problems))
+	for i, p := range problems {
+		lines[i] = p.String()
+	}
+	return errors.New(strings.Join(lines, "; "))
+}
+
+var (
+	// git's scissors line, below which everything is ignored
+	scissorsPattern = regexp.MustCompile(`^# -+ >8 -+$`)
+	// subjects git writes itself, which aren't worth linting
+	generatedPattern = regexp.MustCompile(`^(Merge |Revert "|(fixup|squash|amend)! )`)
+	trailerPattern   = regexp.MustCompile(`^[A-Za-z][\w-]*: \S`)
+)
+
+// Clean removes what git strips from a message file before committing it:
+// comment lines, everything below a scissors line and surrounding blank lines.
+func Clean(message string) string {
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if scissorsPattern.MatchString(line) {
+			break
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t\r"))
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// Lint checks message against rules, returning the problems found. Messages
+// git generates, such as merges and fixups, have no problems.
+func Lint(message string, rules Rules) []Problem {
+	message = Clean(message)
+	lines := strings.Split(message, "\n")
+	subject := lines[0]
+	if subject == "" {
+		return []Problem{{Rule: "subject-empty", Line: 1, Message: "subject is empty"}}
+	}
+	if generatedPattern.MatchString(subject) {
+		return nil
+	}
+
+	var problems []Problem
+	add := func(rule string, line int, format string, args ...any) {
+		problems = append(problems, Problem{Rule: rule, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if n := utf8.RuneCountInString(subject); rules.MaxSubjectLength > 0 && n > rules.MaxSubjectLength {
+		add("subject-length", 1, "subject is %d characters long, more than %d", n, rules.MaxSubjectLength)
+	}
+	if strings.HasSuffix(subject, ".") {
+		add("subject-period", 1, "subject ends with a period")
+	}
+
+	// a ticket prefix isn't part of the convention's subject
+	_, header := convention.SubjectTicket(subject, rules.TicketPattern)
+	parts := convention.Message{Subject: header}
+	if conv := rules.Convention; conv != nil {
+		var ok bool
+		parts, ok = conv.Parse(header)
+		var typeErr *convention.TypeError
+		switch err := conv.Validate(header); {
+		case errors.As(err, &typeErr):
+			add("type", 1, "%v", err)
+		case err != nil && (!ok || !strings.HasSuffix(subject, ".")):
+			// a well-formed subject can only fail on a trailing period, which
+			// was already reported
+			add("convention", 1, "%v", err)
+		}
+	}
+	if len(rules.Scopes) > 0 && parts.Scope != "" && !contains(rules.Scopes, parts.Scope) {
+		add("scope", 1, "scope '%s' isn't one of %s", parts.Scope, strings.Join(rules.Scopes, ", "))
+	}
+	if rules.Imperative {
+		if word, ok := nonImperative(parts.Subject); ok {
+			add("imperative", 1, "subject should start with a verb in the imperative mood (e.g. 'fix', not 'fixed' or 'fixes'), not '%s'", word)
+		}
+	}
+
+	if len(lines) > 1 && lines[1] != "" {
+		add("blank-line", 2, "subject isn't followed by a blank line")
+	}
+	if rules.BodyWidth > 0 {
+		trailers := trailerStart(lines)
+		for i := 1; i < trailers; i++ {
+			if n := utf8.RuneCountInString(lines[i]); n > rules.BodyWidth && wrappable(lines[i]) {
+				add("body-width", i+1, "line is %d characters long, more than %d", n, rules.BodyWidth)
+			}
+		}
+	}
+	if rules.RequireTicket && rules.TicketPattern != nil && !rules.TicketPattern.MatchString(message) {
+		add("ticket", 1, "message doesn't reference a ticket matching %s", rules.TicketPattern)
+	}
+	return problems
+}
+
+// trailerStart returns the index of the first line of the trailer block
+// at the end of the message, or len(lines) if there is none.
+func trailerStart(lines []string) int {
+	start := len(lines)
+	for start > 1 && trailerPattern.MatchString(lines[start-1]) {
+		start--
+	}
+	if start == len(lines) || lines[start-1] != "" {
+		return len(lines)
+	}
+	return start
+}
+
+// wrappable reports whether a long line could have been wrapped. Indented
+// lines such as code and lines without spaces such as URLs can't.
+func wrappable(line string) bool {
+	if strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
+		return false
+	}
+	return strings.Contains(strings.TrimSpace(line), " ")
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
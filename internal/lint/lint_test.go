@@ -0,0 +1,109 @@
+package lint
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/kabilan108/diffgpt/internal/convention"
+)
+
+func mustConvention(t *testing.T, name string) *convention.Convention {
+	t.Helper()
+	c, err := convention.Get(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// rules returns the problems' rules in order.
+func rules(problems []Problem) string {
+	var names []string
+	for _, p := range problems {
+		names = append(names, p.Rule)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestLint(t *testing.T) {
+	conventional := mustConvention(t, "conventional")
+	longLine := strings.Repeat("word ", 16)
+	ticket := regexp.MustCompile(`[A-Z]+-\d+`)
+
+	tests := []struct {
+		name    string
+		message string
+		rules   Rules
+		want    string
+	}{
+		{"valid", "feat(api): add login\n\n- add form\n\nRefs: PROJ-12", DefaultRules(conventional), ""},
+		{"empty", "\n# only a comment\n", DefaultRules(conventional), "subject-empty"},
+		{"long subject", "feat: " + strings.Repeat("a", 70), DefaultRules(conventional), "subject-length"},
+		{"trailing period", "feat: add login.", DefaultRules(conventional), "subject-period"},
+		{"past tense", "fix: fixed crash", DefaultRules(conventional), "imperative"},
+		{"third person", "fix: adds retry", DefaultRules(conventional), "imperative"},
+		{"gerund", "Adding retries", DefaultRules(mustConvention(t, "plain")), "imperative"},
+		{"imperative ending in ed", "feat: embed assets", DefaultRules(conventional), ""},
+		{"mood check disabled", "fix: fixed crash", Rules{Convention: conventional}, ""},
+		{"no blank line", "feat: add login\n- add form", DefaultRules(conventional), "blank-line"},
+		{"wide body", "feat: add login\n\n" + longLine, DefaultRules(conventional), "body-width"},
+		{"wide url", "feat: add login\n\nhttps://example.com/" + strings.Repeat("a", 80), DefaultRules(conventional), ""},
+		{"wide code", "feat: add login\n\n    " + longLine, DefaultRules(conventional), ""},
+		{"wide trailer", "feat: add login\n\nbody\n\nCo-authored-by: " + longLine, DefaultRules(conventional), ""},
+		{"unknown type", "feature: add login", DefaultRules(conventional), "type"},
+		{"not the convention", "Add login", DefaultRules(conventional), "convention"},
+		{"angular casing", "feat: Add login", DefaultRules(mustConvention(t, "angular")), "convention"},
+		{"custom types", "deps: bump cobra", DefaultRules(conventional.WithTypes([]string{"deps"})), ""},
+		{"allowed scope", "feat(api): add login", Rules{Convention: conventional, Scopes: []string{"api"}}, ""},
+		{"unknown scope", "feat(web): add login", Rules{Convention: conventional, Scopes: []string{"api"}}, "scope"},
+		{"kernel scope", "net: fix leak", Rules{Convention: mustConvention(t, "kernel"), Scopes: []string{"mm"}}, "scope"},
+		{"ticket", "feat: add login\n\nRefs: PROJ-12", Rules{TicketPattern: ticket, RequireTicket: true}, ""},
+		{"missing ticket", "feat: add login", Rules{TicketPattern: ticket, RequireTicket: true}, "ticket"},
+		{"optional ticket", "feat: add login", Rules{TicketPattern: ticket}, ""},
+		{"ticket prefix", "[PROJ-12] feat: add login", Rules{Convention: conventional, TicketPattern: ticket, RequireTicket: true}, ""},
+		{"other prefix", "[wip] feat: add login", Rules{Convention: conventional, TicketPattern: ticket}, "convention"},
+		{"branch ticket prefix", convention.AddTicket("feat: add login", "PROJ-12", convention.PlaceSubject),
+			DefaultRules(conventional).WithTicket("PROJ-12"), ""},
+		{"branch ticket outside pattern", "[proj-12] feat: add login",
+			Rules{Convention: conventional, TicketPattern: ticket}.WithTicket("proj-12"), ""},
+		{"other branch's ticket", "[PROJ-9] feat: add login", DefaultRules(conventional).WithTicket("PROJ-12"), "convention"},
+		{"merge", "Merge branch 'main' into feature", DefaultRules(conventional), ""},
+		{"fixup", "fixup! feat: add login", DefaultRules(conventional), ""},
+		{"several problems", "Fixed stuff.\nmore", DefaultRules(conventional), "subject-period,convention,imperative,blank-line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := Lint(tt.message, tt.rules)
+			if got := rules(problems); got != tt.want {
+				t.Errorf("Lint() = %v, want rules %q", problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestLint_Lines(t *testing.T) {
+	message := "# comment\nfeat: add login\n\nshort\n" + strings.Repeat("word ", 16)
+	problems := Lint(message, DefaultRules(mustConvention(t, "conventional")))
+	if len(problems) != 1 || problems[0].Line != 4 {
+		t.Errorf("Expected a body-width problem on line 4 of the cleaned message, got %v", problems)
+	}
+}
+
+func TestClean(t *testing.T) {
+	message := "\nfeat: add login  \n# Please enter the commit message\n\nbody\n" +
+		"# ------------------------ >8 ------------------------\ndiff --git a/f b/f\n"
+	if got, want := Clean(message), "feat: add login\n\nbody"; got != want {
+		t.Errorf("Clean() = %q, want %q", got, want)
+	}
+}
+
+func TestError(t *testing.T) {
+	if Error(nil) != nil {
+		t.Error("Expected no error without problems")
+	}
+	err := Error([]Problem{{Rule: "subject-period", Line: 1, Message: "subject ends with a period"}})
+	if err == nil || err.Error() != "line 1: subject ends with a period (subject-period)" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package lint
+
+import (
+	"strings"
+	"unicode"
+)
+
+// The imperative mood check is a heuristic: it only flags first words that
+// are clearly past tense, gerunds or third person forms of verbs commonly
+// used in commit messages.
+
+// notPastTense end in "ed" but are imperative verbs.
+var notPastTense = map[string]bool{
+	"embed": true, "exceed": true, "feed": true, "need": true, "proceed": true,
+	"seed": true, "shed": true, "speed": true, "succeed": true, "shred": true,
+}
+
+// notGerunds end in "ing" but are imperative verbs.
+var notGerunds = map[string]bool{
+	"bring": true, "ping": true, "ring": true, "sing": true, "spring": true, "sting": true,
+	"string": true, "swing": true, "wing": true,
+}
+
+// thirdPerson are third person forms of verbs common in commit messages.
+var thirdPerson = map[string]bool{
+	"adds": true, "allows": true, "bumps": true, "changes": true, "cleans": true, "converts": true,
+	"creates": true, "deletes": true, "disables": true, "documents": true, "drops": true,
+	"enables": true, "ensures": true, "extracts": true, "fixes": true, "handles": true,
+	"implements": true, "improves": true, "introduces": true, "makes": true, "merges": true,
+	"moves": true, "prevents": true, "refactors": true, "removes": true, "renames": true,
+	"replaces": true, "reverts": true, "sets": true, "simplifies": true, "supports": true,
+	"updates": true, "upgrades": true, "uses": true,
+}
+
+// nonImperative returns the first word of summary if it doesn't look like
+// an imperative verb.
+func nonImperative(summary string) (string, bool) {
+	fields := strings.Fields(summary)
+	if len(fields) == 0 {
+		return "", false
+	}
+	word := strings.TrimFunc(fields[0], func(r rune) bool { return !unicode.IsLetter(r) })
+	lower := strings.ToLower(word)
+	switch {
+	case thirdPerson[lower]:
+		return word, true
+	case len(lower) < 5:
+		// too short to tell, e.g. "shed" or "sing"
+		return "", false
+	case strings.HasSuffix(lower, "ed") && !notPastTense[lower]:
+		return word, true
+	case strings.HasSuffix(lower, "ing") && !notGerunds[lower]:
+		return word, true
+	}
+	return "", false
+}
@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+const (
+	DefaultCacheTTL     = 24 * time.Hour
+	DefaultCacheMaxSize = 20 << 20 // 20 MiB
+
+	cacheFileExt = ".json"
+)
+
+// Cache keeps responses on disk so that repeating an identical request, e.g.
+// after aborting the commit editor, doesn't bill it again. Entries expire
+// after TTL, and the oldest entries are evicted once the cache grows beyond
+// MaxSize bytes.
+type Cache struct {
+	Dir     string
+	TTL     time.Duration
+	MaxSize int64
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir, TTL: DefaultCacheTTL, MaxSize: DefaultCacheMaxSize}
+}
+
+// cacheKey identifies a request by everything sent to the provider: the
+// model, the prompts, the examples, the diff and the output format.
+func cacheKey(baseURL string, params openai.ChatCompletionNewParams) string {
+	data, _ := json.Marshal(params)
+	sum := sha256.Sum256(append([]byte(strings.TrimRight(baseURL, "/")+"\n"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key+cacheFileExt)
+}
+
+// Get returns the response cached for key, if it hasn't expired.
+func (c *Cache) Get(key string) (string, bool) {
+	path := c.path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	if c.expired(info) {
+		_ = os.Remove(path)
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Put caches content as the response for key and evicts expired and, while
+// the cache is too large, the oldest entries.
+func (c *Cache) Put(key, content string) error {
+	if err := os.MkdirAll(c.Dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create cache directory %s: %w", c.Dir, err)
+	}
+	path := c.path(key)
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, []byte(content), 0o600); err != nil {
+		return fmt.Errorf("failed to write cache entry %s: %w", tempFile, err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		_ = os.Remove(tempFile)
+		return fmt.Errorf("failed to write cache entry %s: %w", path, err)
+	}
+	return c.prune()
+}
+
+func (c *Cache) prune() error {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory %s: %w", c.Dir, err)
+	}
+
+	var infos []os.FileInfo
+	var size int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), cacheFileExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if c.expired(info) {
+			_ = os.Remove(filepath.Join(c.Dir, info.Name()))
+			continue
+		}
+		infos = append(infos, info)
+		size += info.Size()
+	}
+
+	// evict the oldest entries first
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().Before(infos[j].ModTime()) })
+	for _, info := range infos {
+		if c.MaxSize <= 0 || size <= c.MaxSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.Dir, info.Name())); err == nil {
+			size -= info.Size()
+		}
+	}
+	return nil
+}
+
+func (c *Cache) expired(info os.FileInfo) bool {
+	return c.TTL > 0 && time.Since(info.ModTime()) > c.TTL
+}
@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache_GetPut(t *testing.T) {
+	cache := NewCache(t.TempDir())
+
+	if _, ok := cache.Get("missing"); ok {
+		t.Error("Expected a miss for an unknown key")
+	}
+	if err := cache.Put("key", `{"message": "fix: cache"}`); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	content, ok := cache.Get("key")
+	if !ok || content != `{"message": "fix: cache"}` {
+		t.Errorf("Get() = %q, %v", content, ok)
+	}
+}
+
+func TestCache_Expires(t *testing.T) {
+	cache := NewCache(t.TempDir())
+	cache.TTL = time.Hour
+
+	if err := cache.Put("key", "content"); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(cache.path("key"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Get("key"); ok {
+		t.Error("Expected an expired entry to miss")
+	}
+	if _, err := os.Stat(cache.path("key")); !os.IsNotExist(err) {
+		t.Error("Expected the expired entry to be removed")
+	}
+}
+
+func TestCache_EvictsOldest(t *testing.T) {
+	cache := NewCache(t.TempDir())
+	cache.MaxSize = 25
+
+	for i, key := range []string{"a", "b", "c"} {
+		if err := cache.Put(key, strings.Repeat("x", 10)); err != nil {
+			t.Fatalf("Put() failed: %v", err)
+		}
+		at := time.Now().Add(time.Duration(i-3) * time.Minute)
+		os.Chtimes(cache.path(key), at, at)
+	}
+	// one more entry pushes the cache over its limit twice over
+	if err := cache.Put("d", strings.Repeat("x", 10)); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+
+	for key, want := range map[string]bool{"a": false, "b": false, "c": true, "d": true} {
+		if _, ok := cache.Get(key); ok != want {
+			t.Errorf("Get(%s) hit = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestGenerate_UsesCache(t *testing.T) {
+	server, calls := failingServer(t, 0, nil)
+	client := NewClient("test-key", server.URL)
+	client.Cache = NewCache(t.TempDir())
+	var usages int
+	client.OnUsage = func(Usage) { usages++ }
+
+	for i := 0; i < 2; i++ {
+		result, err := generateCommit(client)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if result != "fix: retry" {
+			t.Errorf("Unexpected result: %q", result)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected the repeated request to be answered from the cache, got %d requests", got)
+	}
+	if usages != 1 {
+		t.Errorf("Expected usage for 1 request, got %d", usages)
+	}
+
+	// a different prompt isn't answered from the cache
+	_, err := Generate[StructuredCommit](
+		context.Background(), client, "test-model", "commit", "test description",
+		"another prompt", "test system prompt", nil, nil,
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Expected a new request for a different prompt, got %d requests", got)
+	}
+
+	client.Regenerate = true
+	if _, err := generateCommit(client); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("Expected regenerating to skip the cache, got %d requests", got)
+	}
+}
+
+func TestGenerate_StreamsCachedResponse(t *testing.T) {
+	server, calls := failingServer(t, 0, nil)
+	client := NewClient("test-key", server.URL)
+	client.Cache = NewCache(t.TempDir())
+
+	if _, err := generateCommit(client); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var out strings.Builder
+	result, err := Generate[StructuredCommit](
+		context.Background(), client, "test-model", "commit", "test description",
+		"test prompt", "test system prompt", nil, &out,
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected the streamed request to be answered from the cache, got %d requests", got)
+	}
+	if result.Subject != "retry" || out.String() != "retry\n" {
+		t.Errorf("Expected the cached message to be rendered, got %+v and %q", result, out.String())
+	}
+}
@@ -0,0 +1,310 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// OutputMode is how a structured response is requested from the provider.
+// Many OpenAI-compatible servers reject strict JSON schemas, so the modes are
+// probed in order until one works.
+type OutputMode string
+
+const (
+	ModeAuto       OutputMode = ""            // probe for a mode that works
+	ModeJSONSchema OutputMode = "json_schema" // strict json_schema response format
+	ModeJSONObject OutputMode = "json_object" // json_object response format, schema in the prompt
+	ModeTools      OutputMode = "tools"       // forced function call taking the schema as parameters
+	ModeText       OutputMode = "text"        // schema in the prompt, JSON parsed out of the reply
+)
+
+var probeOrder = []OutputMode{ModeJSONSchema, ModeJSONObject, ModeTools, ModeText}
+
+// ParseOutputMode parses an output mode name, where "auto" or "" means probing.
+func ParseOutputMode(s string) (OutputMode, error) {
+	if s == "" || s == "auto" {
+		return ModeAuto, nil
+	}
+	for _, mode := range probeOrder {
+		if string(mode) == s {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output mode %q (expected auto, json_schema, json_object, tools or text)", s)
+}
+
+// ModeCache remembers the output mode that works for each model of a provider.
+type ModeCache interface {
+	OutputMode(baseURL, model string) (OutputMode, bool)
+	SetOutputMode(baseURL, model string, mode OutputMode)
+}
+
+type memoryModeCache struct {
+	mu    sync.Mutex
+	modes map[string]OutputMode
+}
+
+func newMemoryModeCache() *memoryModeCache {
+	return &memoryModeCache{modes: map[string]OutputMode{}}
+}
+
+func (c *memoryModeCache) OutputMode(baseURL, model string) (OutputMode, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mode, ok := c.modes[baseURL+" "+model]
+	return mode, ok
+}
+
+func (c *memoryModeCache) SetOutputMode(baseURL, model string, mode OutputMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.modes[baseURL+" "+model] = mode
+}
+
+// structuredRequest describes a request for a response matching a schema.
+type structuredRequest struct {
+	model        string
+	schemaName   string
+	schemaDesc   string
+	schema       any
+	format       openai.ChatCompletionNewParamsResponseFormatUnion // strict json_schema format
+	systemPrompt string
+	prompt       string
+	examples     []openai.ChatCompletionMessageParamUnion
+}
+
+// generate sends req and passes the response to decode. Unless an output mode
+// is forced, the mode last known to work for the model is tried first,
+// followed by the others in probe order; the first mode that produces a
+// decodable response is remembered.
+func (c *Client) generate(
+	ctx context.Context, req structuredRequest, stream io.Writer, decode func(content string, mode OutputMode) error,
+) error {
+	modes := []OutputMode{c.Mode}
+	known, hasKnown := ModeAuto, false
+	if c.Mode == ModeAuto {
+		known, hasKnown = c.Modes.OutputMode(c.baseURL, req.model)
+		modes = probeOrder
+		if hasKnown {
+			modes = append([]OutputMode{known}, without(probeOrder, known)...)
+		}
+	}
+
+	// one renderer shows every attempt, so each new attempt is set apart from
+	// the partial output of the last
+	var r *streamRenderer
+	if stream != nil {
+		r = newStreamRenderer(stream)
+	}
+	var failures []string
+	for i := 0; ; i++ {
+		mode := modes[i]
+		params := req.params(mode)
+
+		key := cacheKey(c.baseURL, params)
+		content, cached := "", false
+		if c.Cache != nil && !c.Regenerate {
+			content, cached = c.Cache.Get(key)
+		}
+		var err error
+		if cached {
+			if r != nil {
+				r.Reset()
+				r.Write(content)
+				r.Finish()
+			}
+		} else {
+			content, err = c.Retry.do(ctx, func(ctx context.Context) (string, error) {
+				if r != nil {
+					r.Reset()
+				}
+				return c.request(ctx, req.model, params, r)
+			})
+		}
+		if err == nil {
+			err = decode(content, mode)
+		}
+		if err == nil {
+			if c.Mode == ModeAuto && (!hasKnown || mode != known) {
+				c.Modes.SetOutputMode(c.baseURL, req.model, mode)
+			}
+			if c.Cache != nil && !cached {
+				// the cache only saves money, so failing to fill it isn't an error
+				_ = c.Cache.Put(key, content)
+			}
+			return nil
+		}
+
+		failures = append(failures, fmt.Sprintf("%s: %s", mode, describeError(err)))
+		if ctx.Err() != nil || !isCapabilityError(err) || len(failures) == len(modes) {
+			if len(failures) == 1 {
+				return err
+			}
+			return fmt.Errorf("no output mode worked (%s): %w", strings.Join(failures, "; "), err)
+		}
+	}
+}
+
+// request sends a single chat completion request, reporting its usage. The
+// response is rendered to stream as it arrives when stream isn't nil.
+func (c *Client) request(
+	ctx context.Context, model string, params openai.ChatCompletionNewParams, stream *streamRenderer,
+) (string, error) {
+	start := time.Now()
+	var content string
+	var usage openai.CompletionUsage
+	var err error
+	if stream != nil {
+		params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+		content, usage, err = streamCompletion(ctx, c.api, params, stream)
+	} else {
+		content, usage, err = completion(ctx, c.api, params)
+	}
+	if err == nil && c.OnUsage != nil {
+		c.OnUsage(Usage{
+			Model:            model,
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			Latency:          time.Since(start),
+		})
+	}
+	return content, err
+}
+
+// params builds the chat completion request for mode.
+func (r structuredRequest) params(mode OutputMode) openai.ChatCompletionNewParams {
+	systemPrompt := r.systemPrompt
+	if mode == ModeJSONObject || mode == ModeText {
+		systemPrompt += schemaInstructions(r.schema)
+	}
+
+	messages := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(systemPrompt),
+	}
+	// prepend examples before user prompt
+	messages = append(messages, r.examples...)
+	messages = append(messages, openai.UserMessage(r.prompt))
+
+	params := openai.ChatCompletionNewParams{
+		Messages: messages,
+		Model:    shared.ChatModel(r.model),
+	}
+	switch mode {
+	case ModeJSONSchema:
+		params.ResponseFormat = r.format
+	case ModeJSONObject:
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
+		}
+	case ModeTools:
+		params.Tools = []openai.ChatCompletionToolParam{{
+			Function: shared.FunctionDefinitionParam{
+				Name:        r.schemaName,
+				Description: openai.String(r.schemaDesc),
+				Parameters:  schemaParameters(r.schema),
+			},
+		}}
+		params.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{
+			OfChatCompletionNamedToolChoice: &openai.ChatCompletionNamedToolChoiceParam{
+				Function: openai.ChatCompletionNamedToolChoiceFunctionParam{Name: r.schemaName},
+			},
+		}
+	}
+	return params
+}
+
+func schemaInstructions(schema any) string {
+	data, _ := json.Marshal(schema)
+	return fmt.Sprintf(
+		"\nRespond only with a JSON object, without any other text, that matches this JSON schema:\n%s\n", data,
+	)
+}
+
+func schemaParameters(schema any) shared.FunctionParameters {
+	var params shared.FunctionParameters
+	data, _ := json.Marshal(schema)
+	_ = json.Unmarshal(data, &params)
+	return params
+}
+
+// isCapabilityError reports whether err suggests the provider doesn't support
+// the output mode that was used, so another mode might work.
+func isCapabilityError(err error) bool {
+	if errors.Is(err, errInvalidResponse) {
+		return true
+	}
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	// gateways often reject unsupported parameters without saying which, so
+	// any bad request is worth another mode unless the prompt is too long
+	return !isContextLengthError(apiErr)
+}
+
+// decodeResponse decodes content into v. Outside strict json_schema mode the
+// provider doesn't enforce the schema, so the JSON is extracted from any
+// surrounding text and required fields are checked.
+func decodeResponse(content string, mode OutputMode, schema, v any) error {
+	if mode == ModeText {
+		content = extractJSON(content)
+	}
+	if err := json.Unmarshal([]byte(content), v); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidResponse, err)
+	}
+	if mode == ModeJSONSchema {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal([]byte(content), &fields)
+	for _, name := range requiredFields(schema) {
+		if _, ok := fields[name]; !ok {
+			return fmt.Errorf("%w: missing field %q", errInvalidResponse, name)
+		}
+	}
+	return nil
+}
+
+// extractJSON returns the outermost JSON object in s, which models asked for
+// plain text often wrap in a code fence or a sentence of explanation.
+func extractJSON(s string) string {
+	start := strings.Index(s, "{")
+	end := strings.LastIndex(s, "}")
+	if start < 0 || end < start {
+		return s
+	}
+	return s[start : end+1]
+}
+
+func requiredFields(schema any) []string {
+	var s struct {
+		Required []string `json:"required"`
+	}
+	data, _ := json.Marshal(schema)
+	_ = json.Unmarshal(data, &s)
+	return s.Required
+}
+
+func without(modes []OutputMode, mode OutputMode) []OutputMode {
+	var rest []OutputMode
+	for _, m := range modes {
+		if m != mode {
+			rest = append(rest, m)
+		}
+	}
+	return rest
+}
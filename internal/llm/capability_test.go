@@ -0,0 +1,228 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeProvider emulates an OpenAI-compatible server that only supports some
+// output modes, replying with content in the way each mode expects.
+type fakeProvider struct {
+	supports map[OutputMode]bool
+	content  string // returned for every supported request
+	asked    []OutputMode
+}
+
+func (p *fakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ResponseFormat *struct {
+			Type string `json:"type"`
+		} `json:"response_format"`
+		Tools []json.RawMessage `json:"tools"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	mode := ModeText
+	switch {
+	case body.ResponseFormat != nil:
+		mode = OutputMode(body.ResponseFormat.Type)
+	case len(body.Tools) > 0:
+		mode = ModeTools
+	}
+	p.asked = append(p.asked, mode)
+
+	if !p.supports[mode] {
+		http.Error(w, `{"error": {"message": "unsupported parameter"}}`, http.StatusBadRequest)
+		return
+	}
+	message := map[string]any{"role": "assistant", "content": p.content}
+	if mode == ModeTools {
+		message = map[string]any{"role": "assistant", "content": "", "tool_calls": []map[string]any{{
+			"id": "call_1", "type": "function",
+			"function": map[string]string{"name": "commit", "arguments": p.content},
+		}}}
+	}
+	data, _ := json.Marshal(map[string]any{
+		"id": "chatcmpl-test", "object": "chat.completion", "created": 0, "model": "test-model",
+		"choices": []map[string]any{{"index": 0, "finish_reason": "stop", "message": message}},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func newProviderClient(t *testing.T, p *fakeProvider) *Client {
+	t.Helper()
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+	recordSleeps(t)
+	client := NewClient("test-key", server.URL)
+	client.Retry.MaxAttempts = 1
+	return client
+}
+
+func TestGenerate_ProbesOutputModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		supports []OutputMode
+		content  string
+		want     OutputMode
+		asked    string
+	}{
+		{
+			name:     "strict json schema",
+			supports: []OutputMode{ModeJSONSchema, ModeJSONObject, ModeTools, ModeText},
+			content:  `{"type": "fix", "scope": "", "subject": "probe", "body": "", "breaking": false, "footers": []}`,
+			want:     ModeJSONSchema,
+			asked:    "[json_schema]",
+		},
+		{
+			name:     "json object",
+			supports: []OutputMode{ModeJSONObject, ModeTools, ModeText},
+			content:  `{"type": "fix", "scope": "", "subject": "probe", "body": "", "breaking": false, "footers": []}`,
+			want:     ModeJSONObject,
+			asked:    "[json_schema json_object]",
+		},
+		{
+			name:     "tools",
+			supports: []OutputMode{ModeTools, ModeText},
+			content:  `{"type": "fix", "scope": "", "subject": "probe", "body": "", "breaking": false, "footers": []}`,
+			want:     ModeTools,
+			asked:    "[json_schema json_object tools]",
+		},
+		{
+			name:     "plain text with a code fence",
+			supports: []OutputMode{ModeText},
+			content:  "Sure, here it is:\n```json\n" + `{"type": "fix", "scope": "", "subject": "probe", "body": "", "breaking": false, "footers": []}` + "\n```",
+			want:     ModeText,
+			asked:    "[json_schema json_object tools text]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &fakeProvider{supports: map[OutputMode]bool{}, content: tt.content}
+			for _, mode := range tt.supports {
+				p.supports[mode] = true
+			}
+			client := newProviderClient(t, p)
+
+			result, err := generateCommit(client)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result != "fix: probe" {
+				t.Errorf("Unexpected result: %q", result)
+			}
+			if fmt.Sprint(p.asked) != tt.asked {
+				t.Errorf("Expected modes %s to be tried, got %v", tt.asked, p.asked)
+			}
+			if mode, ok := client.Modes.OutputMode(client.baseURL, "test-model"); !ok || mode != tt.want {
+				t.Errorf("Expected %s to be remembered, got %q", tt.want, mode)
+			}
+
+			// the remembered mode is used straight away next time
+			p.asked = nil
+			if _, err := generateCommit(client); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if fmt.Sprint(p.asked) != fmt.Sprintf("[%s]", tt.want) {
+				t.Errorf("Expected only %s to be tried, got %v", tt.want, p.asked)
+			}
+		})
+	}
+}
+
+func TestGenerate_MissingFieldTriesNextMode(t *testing.T) {
+	// json_object mode doesn't enforce the schema, so the reply can be any object
+	p := &fakeProvider{supports: map[OutputMode]bool{ModeJSONObject: true}, content: `{"commit": "fix: probe"}`}
+	client := newProviderClient(t, p)
+
+	_, err := generateCommit(client)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if fmt.Sprint(p.asked) != "[json_schema json_object tools text]" {
+		t.Errorf("Expected every mode to be tried, got %v", p.asked)
+	}
+	if !strings.Contains(err.Error(), `json_object: response did not match the requested schema: missing field "type"`) {
+		t.Errorf("Expected the failures to be summarized, got: %v", err)
+	}
+}
+
+func TestGenerate_ForcedMode(t *testing.T) {
+	p := &fakeProvider{supports: map[OutputMode]bool{ModeText: true}, content: `{"type": "fix", "scope": "", "subject": "probe", "body": "", "breaking": false, "footers": []}`}
+	client := newProviderClient(t, p)
+	client.Mode = ModeJSONObject
+
+	if _, err := generateCommit(client); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if fmt.Sprint(p.asked) != "[json_object]" {
+		t.Errorf("Expected only the forced mode to be tried, got %v", p.asked)
+	}
+}
+
+func TestGenerate_RememberedModeReprobed(t *testing.T) {
+	p := &fakeProvider{supports: map[OutputMode]bool{ModeTools: true}, content: `{"type": "fix", "scope": "", "subject": "probe", "body": "", "breaking": false, "footers": []}`}
+	client := newProviderClient(t, p)
+	client.Modes.SetOutputMode(client.baseURL, "test-model", ModeJSONObject)
+
+	if _, err := generateCommit(client); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if fmt.Sprint(p.asked) != "[json_object json_schema tools]" {
+		t.Errorf("Expected the remembered mode to be tried first, got %v", p.asked)
+	}
+	if mode, _ := client.Modes.OutputMode(client.baseURL, "test-model"); mode != ModeTools {
+		t.Errorf("Expected tools to be remembered, got %q", mode)
+	}
+}
+
+func TestGenerate_AuthErrorStopsProbing(t *testing.T) {
+	server, calls := failingServer(t, 100, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"message": "invalid api key"}}`, http.StatusUnauthorized)
+	})
+	recordSleeps(t)
+
+	_, err := Generate[StructuredCommit](
+		context.Background(), NewClient("test-key", server.URL), "test-model", "commit", "test description",
+		"test prompt", "test system prompt", nil, nil,
+	)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected 1 request, got %d", got)
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`{"message": "fix"}`, `{"message": "fix"}`},
+		{"```json\n{\"message\": \"fix\"}\n```", `{"message": "fix"}`},
+		{`Here you go: {"message": "fix {braces}"} Hope that helps!`, `{"message": "fix {braces}"}`},
+		{"no json here", "no json here"},
+	}
+	for _, tt := range tests {
+		if got := extractJSON(tt.in); got != tt.want {
+			t.Errorf("extractJSON(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseOutputMode(t *testing.T) {
+	for _, s := range []string{"", "auto", "json_schema", "json_object", "tools", "text"} {
+		if _, err := ParseOutputMode(s); err != nil {
+			t.Errorf("ParseOutputMode(%q) failed: %v", s, err)
+		}
+	}
+	if _, err := ParseOutputMode("xml"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
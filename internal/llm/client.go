@@ -3,22 +3,38 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
+	"time"
 
 	"github.com/invopop/jsonschema"
-	"github.com/kabilan108/diffgpt/internal/config"
+	"github.com/kabilan108/diffgpt/internal/convention"
+	"github.com/kabilan108/diffgpt/internal/prompt"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
-	"github.com/openai/openai-go/shared"
 )
 
-type Commit struct {
-	Message string `json:"message" jsonschema_description:"Single-line commit message that adheres to conventional commits."`
+// StructuredCommit is a commit message broken into parts, which are
+// assembled into the final message by the commit convention.
+type StructuredCommit struct {
+	Type     string   `json:"type" jsonschema_description:"Kind of change."`
+	Scope    string   `json:"scope" jsonschema_description:"Part of the codebase the change affects, or an empty string if it affects many parts."`
+	Subject  string   `json:"subject" jsonschema_description:"Short summary of the change in the imperative mood, without a type or scope prefix and without a trailing period."`
+	Body     string   `json:"body" jsonschema_description:"Description of the changes made, written as concise bullet points in markdown"`
+	Breaking bool     `json:"breaking" jsonschema_description:"Whether the change breaks backwards compatibility."`
+	Footers  []Footer `json:"footers" jsonschema_description:"Trailers such as references to issues, only if the diff or instructions call for them."`
 }
 
-type DetailedCommit struct {
-	Message string `json:"message" jsonschema_description:"Single-line commit message that adheres to conventional commits."`
-	Details string `json:"details" jsonschema_description:"Description of the changes made, written as concise bullet points in markdown"`
+type Footer struct {
+	Token string `json:"token" jsonschema_description:"Trailer name, e.g. Refs or BREAKING CHANGE."`
+	Value string `json:"value" jsonschema_description:"Trailer value."`
+}
+
+type Judgement struct {
+	Score  int    `json:"score" jsonschema_description:"Quality of the commit message as an example to imitate, from 1 (useless) to 10 (exemplary)."`
+	Reason string `json:"reason" jsonschema_description:"One sentence explaining the score."`
 }
 
 func GenerateSchema[T any]() any {
@@ -34,10 +50,14 @@ func GenerateSchema[T any]() any {
 }
 
 func newResponseSchema[T any](name string, desc string) openai.ChatCompletionNewParamsResponseFormatUnion {
+	return responseFormat(name, desc, GenerateSchema[T]())
+}
+
+func responseFormat(name, desc string, schema any) openai.ChatCompletionNewParamsResponseFormatUnion {
 	jsonSchema := openai.ResponseFormatJSONSchemaJSONSchemaParam{
 		Name:        name,
 		Description: openai.String(desc),
-		Schema:      GenerateSchema[T](),
+		Schema:      schema,
 		Strict:      openai.Bool(true),
 	}
 	return openai.ChatCompletionNewParamsResponseFormatUnion{
@@ -47,91 +67,337 @@ func newResponseSchema[T any](name string, desc string) openai.ChatCompletionNew
 	}
 }
 
-func NewClient(apiKey, baseURL string) openai.Client {
-	client := openai.NewClient(
+// Client is an OpenAI-compatible chat client that applies diffgpt's retry
+// policy to every request.
+type Client struct {
+	api     openai.Client
+	baseURL string
+	Retry   RetryPolicy
+	// Mode forces an output mode; by default the mode is probed.
+	Mode OutputMode
+	// Modes remembers probed output modes, by default only in memory.
+	Modes ModeCache
+	// OnUsage is called with the tokens and time spent on every completed request.
+	OnUsage func(Usage)
+	// Cache, if set, answers repeated requests without calling the provider.
+	Cache *Cache
+	// Regenerate ignores cached responses, replacing them with fresh ones.
+	Regenerate bool
+}
+
+// Usage describes the tokens and time spent on a single request.
+type Usage struct {
+	Model            string
+	PromptTokens     int64
+	CompletionTokens int64
+	Latency          time.Duration
+}
+
+func NewClient(apiKey, baseURL string) *Client {
+	api := openai.NewClient(
 		option.WithAPIKey(apiKey),
 		option.WithBaseURL(baseURL),
+		// retries are handled by Client.Retry
+		option.WithMaxRetries(0),
 	)
-	return client
+	return &Client{api: api, baseURL: baseURL, Retry: DefaultRetryPolicy, Modes: newMemoryModeCache()}
 }
 
-func Generate[T Commit | DetailedCommit](
-	ctx context.Context, client openai.Client,
+// Generate requests a structured response of type T. If stream is not nil,
+// the response is streamed and its text fields are rendered to stream as
+// they arrive.
+func Generate[T StructuredCommit | Judgement](
+	ctx context.Context, client *Client,
 	model, schemaName, schemaDesc, prompt, systemPrompt string,
-	examples []openai.ChatCompletionMessageParamUnion,
+	examples []openai.ChatCompletionMessageParamUnion, stream io.Writer,
 ) (T, error) {
-	messages := []openai.ChatCompletionMessageParamUnion{
-		openai.SystemMessage(systemPrompt),
-	}
+	return generateWithSchema[T](
+		ctx, client, model, schemaName, schemaDesc, GenerateSchema[T](), prompt, systemPrompt, examples, stream,
+	)
+}
 
-	// prepend examples before user prompt
-	messages = append(messages, examples...)
-	messages = append(messages, openai.UserMessage(prompt))
+// generateWithSchema is Generate with a schema that has been adjusted from
+// the one reflected from T, e.g. to restrict the values of a field.
+func generateWithSchema[T any](
+	ctx context.Context, client *Client,
+	model, schemaName, schemaDesc string, schema any, prompt, systemPrompt string,
+	examples []openai.ChatCompletionMessageParamUnion, stream io.Writer,
+) (T, error) {
+	var zero T
+	req := structuredRequest{
+		model:        model,
+		schemaName:   schemaName,
+		schemaDesc:   schemaDesc,
+		schema:       schema,
+		format:       responseFormat(schemaName, schemaDesc, schema),
+		systemPrompt: systemPrompt,
+		prompt:       prompt,
+		examples:     examples,
+	}
 
-	completion, err := client.Chat.Completions.New(ctx,
-		openai.ChatCompletionNewParams{
-			Messages:       messages,
-			ResponseFormat: newResponseSchema[T](schemaName, schemaDesc),
-			Model:          shared.ChatModel(model),
-		})
+	var resp T
+	err := client.generate(ctx, req, stream, func(content string, mode OutputMode) error {
+		resp = zero
+		return decodeResponse(content, mode, req.schema, &resp)
+	})
 	if err != nil {
-		var zero T
+		if ctx.Err() != nil {
+			return zero, fmt.Errorf("generation cancelled: %w", ctx.Err())
+		}
+		if errors.Is(err, errInvalidResponse) {
+			return zero, err
+		}
 		return zero, fmt.Errorf("failed to call chat completion API: %w", err)
 	}
 
-	var resp T
-	err = json.Unmarshal([]byte(completion.Choices[0].Message.Content), &resp)
+	return resp, nil
+}
+
+func completion(
+	ctx context.Context, client openai.Client, params openai.ChatCompletionNewParams,
+) (string, openai.CompletionUsage, error) {
+	completion, err := client.Chat.Completions.New(ctx, params)
 	if err != nil {
-		var zero T
-		return zero, err
+		return "", openai.CompletionUsage{}, err
+	}
+	if len(completion.Choices) == 0 {
+		return "", completion.Usage, fmt.Errorf("response contained no choices")
 	}
+	message := completion.Choices[0].Message
+	if len(message.ToolCalls) > 0 {
+		return message.ToolCalls[0].Function.Arguments, completion.Usage, nil
+	}
+	return message.Content, completion.Usage, nil
+}
 
-	return resp, nil
+func streamCompletion(
+	ctx context.Context, client openai.Client, params openai.ChatCompletionNewParams, r *streamRenderer,
+) (string, openai.CompletionUsage, error) {
+	stream := client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	var content strings.Builder
+	var usage openai.CompletionUsage
+	for stream.Next() {
+		chunk := stream.Current()
+		// usage arrives in a final chunk without choices
+		if chunk.Usage.TotalTokens > 0 {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta.Content
+		// in tools mode the response arrives as the arguments of a call
+		for _, call := range chunk.Choices[0].Delta.ToolCalls {
+			delta += call.Function.Arguments
+		}
+		content.WriteString(delta)
+		r.Write(delta)
+	}
+	r.Finish()
+	if err := stream.Err(); err != nil {
+		return "", usage, err
+	}
+	return content.String(), usage, nil
 }
 
 func createUserMessage(diff string) string {
 	return fmt.Sprintf("Generate a commit message for the following diff:\n```diff\n%s\n```", diff)
 }
 
-func formatExamples(examples []config.Example) []openai.ChatCompletionMessageParamUnion {
+func formatExamples(examples []prompt.Exchange) []openai.ChatCompletionMessageParamUnion {
 	apiExamples := make([]openai.ChatCompletionMessageParamUnion, 0, len(examples)*2)
 	for _, ex := range examples {
-		userMessage := createUserMessage(ex.Diff)
-		apiExamples = append(apiExamples, openai.UserMessage(userMessage))
-		apiExamples = append(apiExamples, openai.AssistantMessage(ex.Message))
+		apiExamples = append(apiExamples, openai.UserMessage(ex.User))
+		apiExamples = append(apiExamples, openai.AssistantMessage(ex.Assistant))
 	}
 	return apiExamples
 }
 
-func GenerateCommitMessage(
-	ctx context.Context, client openai.Client, model, diff string, detailed bool,
-	examples []config.Example,
-) (string, error) {
-	systemMessage := `You are an expert programmer assisting with writing git commit messages.
-Analyze the provided code diff and generate a concise, informative commit message following
-conventional commit standards (e.g., "feat: add user login functionality").
-The commit message should accurately describe the changes. Do not include explanations or apologies.
-`
-	userMessage := createUserMessage(diff)
-	apiExamples := formatExamples(examples)
-
-	if detailed {
-		r, err := Generate[DetailedCommit](
-			ctx, client, model, "detailed_commit",
-			"a git commit message with a description of the changes made",
-			userMessage, systemMessage, apiExamples,
+// Options configures commit message generation.
+type Options struct {
+	// Models are tried in order, moving to the next one when a model is
+	// unavailable, rejects structured output or can't fit the prompt.
+	Models   []string
+	Detailed bool
+	// Convention assembles the message from the parts the model chose,
+	// conventional commits if nil.
+	Convention *convention.Convention
+	// Scopes, if set, are the only scopes the model may choose from.
+	Scopes []string
+	// Ticket, if set, is referenced in the message as TicketPlacement says.
+	Ticket          string
+	TicketPlacement convention.Placement
+	// Check, if set, reviews each message. A message it rejects is sent back
+	// to the model with the error, up to MaxRevisions times, after which the
+	// last message is returned anyway.
+	Check        func(message string) error
+	MaxRevisions int
+	// OnRevise is called before asking the model to revise a rejected message.
+	OnRevise func(err error)
+	// Stream receives the message as it is generated, if set.
+	Stream io.Writer
+	// OnFallback is called before moving from a failed model to the next one.
+	OnFallback func(failed, next string, err error)
+}
+
+// Result is a generated commit message and the model that produced it.
+type Result struct {
+	Message string
+	Model   string
+}
+
+// GenerateCommitMessage asks for a commit message using the rendered prompt p.
+func GenerateCommitMessage(ctx context.Context, client *Client, p prompt.Prompt, opts Options) (Result, error) {
+	var failures []string
+	for i, model := range opts.Models {
+		message, err := generateCommitMessage(ctx, client, model, p, opts)
+		if err == nil {
+			return Result{Message: message, Model: model}, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s: %s", model, describeError(err)))
+		if ctx.Err() != nil || !shouldFallback(err) || i == len(opts.Models)-1 {
+			if len(failures) == 1 {
+				return Result{}, err
+			}
+			return Result{}, fmt.Errorf("tried %d models (%s): %w", len(failures), strings.Join(failures, "; "), err)
+		}
+		if opts.OnFallback != nil {
+			opts.OnFallback(model, opts.Models[i+1], err)
+		}
+	}
+	return Result{}, errors.New("no model configured")
+}
+
+func generateCommitMessage(ctx context.Context, client *Client, model string, p prompt.Prompt, opts Options) (string, error) {
+	conv := opts.Convention
+	if conv == nil {
+		conv, _ = convention.Get(convention.Default)
+	}
+	name, desc := "commit", "a git commit message"
+	if opts.Detailed {
+		name, desc = "detailed_commit", "a git commit message with a description of the changes made"
+	}
+	schema := commitSchema(conv, opts.Scopes, opts.Detailed)
+	examples := formatExamples(p.Examples)
+	userPrompt := p.User
+
+	for revision := 0; ; revision++ {
+		r, err := generateWithSchema[StructuredCommit](
+			ctx, client, model, name, desc, schema, userPrompt, p.System, examples, opts.Stream,
 		)
 		if err != nil {
 			return "", err
 		}
-		return fmt.Sprintf("%s\n\n%s", r.Message, r.Details), nil
+		if !opts.Detailed {
+			// only strict json_schema mode keeps the model from adding one
+			r.Body = ""
+		}
+		message := convention.AddTicket(conv.Format(r.message()), opts.Ticket, opts.TicketPlacement)
+		if opts.Check == nil {
+			return message, nil
+		}
+		checkErr := opts.Check(message)
+		if checkErr == nil || revision >= opts.MaxRevisions {
+			return message, nil
+		}
+		if opts.OnRevise != nil {
+			opts.OnRevise(checkErr)
+		}
+
+		// continue the conversation so the model sees its own answer
+		answer, _ := json.Marshal(r)
+		examples = append(examples, openai.UserMessage(userPrompt), openai.AssistantMessage(string(answer)))
+		userPrompt = fmt.Sprintf(
+			"That commit message, once formatted, was:\n```\n%s\n```\nIt breaks these rules: %v\n"+
+				"Respond with a corrected commit message for the same diff.", message, checkErr,
+		)
+	}
+}
+
+func (r StructuredCommit) message() convention.Message {
+	m := convention.Message{
+		Type:     r.Type,
+		Scope:    r.Scope,
+		Subject:  r.Subject,
+		Body:     r.Body,
+		Breaking: r.Breaking,
+	}
+	for _, f := range r.Footers {
+		m.Footers = append(m.Footers, convention.Footer{Token: f.Token, Value: f.Value})
+	}
+	return m
+}
+
+// commitSchema returns the schema of StructuredCommit without the fields conv
+// doesn't use, limiting types to the convention's and scopes to scopes if any
+// are given. The body is only asked for in detailed messages.
+func commitSchema(conv *convention.Convention, scopes []string, detailed bool) *jsonschema.Schema {
+	schema := GenerateSchema[StructuredCommit]().(*jsonschema.Schema)
+	drop := func(field string) {
+		schema.Properties.Delete(field)
+		var required []string
+		for _, name := range schema.Required {
+			if name != field {
+				required = append(required, name)
+			}
+		}
+		schema.Required = required
+	}
+	property := func(field string) *jsonschema.Schema {
+		p, _ := schema.Properties.Get(field)
+		return p
+	}
+
+	if !conv.Fields.Type {
+		drop("type")
+	} else {
+		if conv.TypeHint != "" {
+			property("type").Description = conv.TypeHint
+		}
+		for _, t := range conv.Types {
+			property("type").Enum = append(property("type").Enum, t)
+		}
 	}
+	if !conv.Fields.Scope {
+		drop("scope")
+	} else {
+		if conv.ScopeHint != "" {
+			property("scope").Description = conv.ScopeHint
+		}
+		if len(scopes) > 0 {
+			for _, s := range scopes {
+				property("scope").Enum = append(property("scope").Enum, s)
+			}
+			// the change may not belong to any single scope
+			property("scope").Enum = append(property("scope").Enum, "")
+		}
+	}
+	if !conv.Fields.Breaking {
+		drop("breaking")
+	}
+	if !detailed {
+		drop("body")
+	}
+	return schema
+}
+
+// JudgeExample asks the model how good message is as a description of diff,
+// returning a score between 0 and 1.
+func JudgeExample(ctx context.Context, client *Client, model, diff, message string) (float64, error) {
+	systemMessage := `You are reviewing historical git commits to pick examples that teach good commit messages.
+Rate how accurately, specifically and concisely the commit message describes the diff.
+A vague message such as "fix" or "update" for a large change should score low.
+`
+	userMessage := fmt.Sprintf("%s\n\nCommit message:\n```\n%s\n```", createUserMessage(diff), message)
 
-	r, err := Generate[Commit](
-		ctx, client, model, "commit", "a git commit message", userMessage, systemMessage, apiExamples,
+	r, err := Generate[Judgement](
+		ctx, client, model, "judgement", "a rating of a commit message", userMessage, systemMessage, nil, nil,
 	)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
-	return r.Message, nil
+	score := min(max(r.Score, 1), 10)
+	return float64(score-1) / 9, nil
 }
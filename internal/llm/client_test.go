@@ -7,6 +7,8 @@ import (
 	"testing"
 
 	"github.com/kabilan108/diffgpt/internal/config"
+	"github.com/kabilan108/diffgpt/internal/convention"
+	"github.com/kabilan108/diffgpt/internal/prompt"
 	"github.com/openai/openai-go"
 )
 
@@ -83,7 +85,7 @@ func TestGenerateSchema(t *testing.T) {
 			name: "commit schema",
 		},
 		{
-			name: "detailed commit schema",
+			name: "judgement schema",
 		},
 	}
 
@@ -91,9 +93,9 @@ func TestGenerateSchema(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			var result any
 			if tt.name == "commit schema" {
-				result = GenerateSchema[Commit]()
+				result = GenerateSchema[StructuredCommit]()
 			} else {
-				result = GenerateSchema[DetailedCommit]()
+				result = GenerateSchema[Judgement]()
 			}
 
 			// Just verify the schema is not nil
@@ -108,7 +110,7 @@ func TestNewResponseSchema(t *testing.T) {
 	name := "test_schema"
 	desc := "test description"
 
-	result := newResponseSchema[Commit](name, desc)
+	result := newResponseSchema[StructuredCommit](name, desc)
 
 	// Check that the schema was created properly
 	if result.OfJSONSchema == nil {
@@ -137,10 +139,10 @@ func TestCreateUserMessage(t *testing.T) {
 }
 
 func TestFormatExamples(t *testing.T) {
-	examples := []config.Example{
+	examples := []prompt.Exchange{
 		{
-			Diff:    "- removed\n+ added",
-			Message: "feat: test example",
+			User:      createUserMessage("- removed\n+ added"),
+			Assistant: "feat: test example",
 		},
 	}
 
@@ -153,7 +155,7 @@ func TestFormatExamples(t *testing.T) {
 }
 
 // MockedGenerate is a function that mimics the Generate function but accepts our mock client
-func MockedGenerate[T Commit | DetailedCommit](
+func MockedGenerate[T StructuredCommit | Judgement](
 	ctx context.Context, client *mockOpenAIClient,
 	model, schemaName, schemaDesc, prompt, systemPrompt string,
 	examples []openai.ChatCompletionMessageParamUnion,
@@ -186,27 +188,27 @@ conventional commit standards (e.g., "feat: add user login functionality").
 The commit message should accurately describe the changes. Do not include explanations or apologies.
 `
 	userMessage := createUserMessage(diff)
-	apiExamples := formatExamples(examples)
+	exchanges := make([]prompt.Exchange, len(examples))
+	for i, ex := range examples {
+		exchanges[i] = prompt.Exchange{User: createUserMessage(ex.Diff), Assistant: ex.Message}
+	}
+	apiExamples := formatExamples(exchanges)
 
+	name, desc := "commit", "a git commit message"
 	if detailed {
-		r, err := MockedGenerate[DetailedCommit](
-			ctx, client, model, "detailed_commit",
-			"a git commit message with a description of the changes made",
-			userMessage, systemMessage, apiExamples,
-		)
-		if err != nil {
-			return "", err
-		}
-		return fmt.Sprintf("%s\n\n%s", r.Message, r.Details), nil
+		name, desc = "detailed_commit", "a git commit message with a description of the changes made"
 	}
-
-	r, err := MockedGenerate[Commit](
-		ctx, client, model, "commit", "a git commit message", userMessage, systemMessage, apiExamples,
+	r, err := MockedGenerate[StructuredCommit](
+		ctx, client, model, name, desc, userMessage, systemMessage, apiExamples,
 	)
 	if err != nil {
 		return "", err
 	}
-	return r.Message, nil
+	if !detailed {
+		r.Body = ""
+	}
+	conv, _ := convention.Get(convention.Default)
+	return conv.Format(r.message()), nil
 }
 
 func TestGenerate(t *testing.T) {
@@ -214,7 +216,7 @@ func TestGenerate(t *testing.T) {
 
 	t.Run("successful generation", func(t *testing.T) {
 		// Create expected response
-		expectedCommit := Commit{Message: "feat: add new feature"}
+		expectedCommit := StructuredCommit{Type: "feat", Subject: "add new feature"}
 		responseJSON, err := json.Marshal(expectedCommit)
 		if err != nil {
 			t.Fatalf("Failed to marshal test response: %v", err)
@@ -224,7 +226,7 @@ func TestGenerate(t *testing.T) {
 		client := createMockClient(string(responseJSON), nil)
 
 		// Call our mocked version
-		result, err := MockedGenerate[Commit](
+		result, err := MockedGenerate[StructuredCommit](
 			ctx, client,
 			"gpt-4", "commit", "test description",
 			"test prompt", "test system prompt", nil,
@@ -234,8 +236,8 @@ func TestGenerate(t *testing.T) {
 			t.Fatalf("Expected no error, got: %v", err)
 		}
 
-		if result.Message != expectedCommit.Message {
-			t.Errorf("Expected message %q, got %q", expectedCommit.Message, result.Message)
+		if result.Type != expectedCommit.Type || result.Subject != expectedCommit.Subject {
+			t.Errorf("Expected commit %+v, got %+v", expectedCommit, result)
 		}
 	})
 
@@ -244,7 +246,7 @@ func TestGenerate(t *testing.T) {
 		client := createMockClient("", fmt.Errorf("API error"))
 
 		// Call our mocked version
-		_, err := MockedGenerate[Commit](
+		_, err := MockedGenerate[StructuredCommit](
 			ctx, client,
 			"gpt-4", "commit", "test description",
 			"test prompt", "test system prompt", nil,
@@ -261,7 +263,7 @@ func TestGenerate(t *testing.T) {
 		client := createMockClient("{invalid json", nil)
 
 		// Call our mocked version
-		_, err := MockedGenerate[Commit](
+		_, err := MockedGenerate[StructuredCommit](
 			ctx, client,
 			"gpt-4", "commit", "test description",
 			"test prompt", "test system prompt", nil,
@@ -280,8 +282,7 @@ func TestGenerateCommitMessage(t *testing.T) {
 
 	t.Run("simple commit", func(t *testing.T) {
 		// Create expected response
-		expectedCommit := Commit{Message: "feat: add new feature"}
-		responseJSON, _ := json.Marshal(expectedCommit)
+		responseJSON, _ := json.Marshal(StructuredCommit{Type: "feat", Subject: "add new feature"})
 
 		// Create mock client
 		client := createMockClient(string(responseJSON), nil)
@@ -293,18 +294,18 @@ func TestGenerateCommitMessage(t *testing.T) {
 			t.Fatalf("Expected no error, got: %v", err)
 		}
 
-		if message != expectedCommit.Message {
-			t.Errorf("Expected message %q, got %q", expectedCommit.Message, message)
+		if expected := "feat: add new feature"; message != expected {
+			t.Errorf("Expected message %q, got %q", expected, message)
 		}
 	})
 
 	t.Run("detailed commit", func(t *testing.T) {
 		// Create expected response
-		expectedCommit := DetailedCommit{
-			Message: "feat: add new feature",
-			Details: "- Added X\n- Improved Y",
-		}
-		responseJSON, _ := json.Marshal(expectedCommit)
+		responseJSON, _ := json.Marshal(StructuredCommit{
+			Type:    "feat",
+			Subject: "add new feature",
+			Body:    "- Added X\n- Improved Y",
+		})
 
 		// Create mock client
 		client := createMockClient(string(responseJSON), nil)
@@ -316,7 +317,7 @@ func TestGenerateCommitMessage(t *testing.T) {
 			t.Fatalf("Expected no error, got: %v", err)
 		}
 
-		expected := fmt.Sprintf("%s\n\n%s", expectedCommit.Message, expectedCommit.Details)
+		expected := "feat: add new feature\n\n- Added X\n- Improved Y"
 		if message != expected {
 			t.Errorf("Expected message %q, got %q", expected, message)
 		}
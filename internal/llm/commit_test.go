@@ -0,0 +1,227 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kabilan108/diffgpt/internal/convention"
+)
+
+// requestedSchema is the part of a json_schema response format the tests
+// look at.
+type requestedSchema struct {
+	Properties map[string]struct {
+		Description string   `json:"description"`
+		Enum        []string `json:"enum"`
+	} `json:"properties"`
+	Required []string `json:"required"`
+}
+
+func TestCommitSchema(t *testing.T) {
+	get := func(name string) *convention.Convention {
+		c, err := convention.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name       string
+		convention *convention.Convention
+		scopes     []string
+		detailed   bool
+		fields     []string
+		types      []string
+		scopeEnum  []string
+	}{
+		{"conventional", get("conventional"), nil, false,
+			[]string{"type", "scope", "subject", "breaking", "footers"}, get("conventional").Types, nil},
+		{"detailed", get("conventional"), nil, true,
+			[]string{"type", "scope", "subject", "body", "breaking", "footers"}, get("conventional").Types, nil},
+		{"custom types and scopes", get("angular").WithTypes([]string{"feat", "fix"}), []string{"api", "cli"}, false,
+			[]string{"type", "scope", "subject", "footers"}, []string{"feat", "fix"}, []string{"api", "cli", ""}},
+		{"gitmoji", get("gitmoji"), []string{"api"}, false, []string{"type", "subject", "footers"}, nil, nil},
+		{"kernel", get("kernel"), nil, false, []string{"scope", "subject", "footers"}, nil, nil},
+		{"plain", get("plain"), nil, true, []string{"subject", "body", "footers"}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(commitSchema(tt.convention, tt.scopes, tt.detailed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var schema requestedSchema
+			if err := json.Unmarshal(data, &schema); err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(schema.Required, tt.fields) {
+				t.Errorf("Required = %v, want %v", schema.Required, tt.fields)
+			}
+			if len(schema.Properties) != len(tt.fields) {
+				t.Errorf("Expected %d properties, got %s", len(tt.fields), data)
+			}
+			if got := schema.Properties["type"].Enum; !slices.Equal(got, tt.types) {
+				t.Errorf("type enum = %v, want %v", got, tt.types)
+			}
+			if got := schema.Properties["scope"].Enum; !slices.Equal(got, tt.scopeEnum) {
+				t.Errorf("scope enum = %v, want %v", got, tt.scopeEnum)
+			}
+		})
+	}
+
+	// hints replace the generic descriptions
+	data, _ := json.Marshal(commitSchema(get("gitmoji"), nil, false))
+	var schema requestedSchema
+	json.Unmarshal(data, &schema)
+	if schema.Properties["type"].Description != get("gitmoji").TypeHint {
+		t.Errorf("Expected the gitmoji type hint, got %q", schema.Properties["type"].Description)
+	}
+}
+
+func TestGenerateCommitMessage_Assembled(t *testing.T) {
+	content := `{"type": "Feat", "scope": "(auth)", "subject": "Add login form.", ` +
+		`"body": "- add a login form that remembers the user between visits so they don't have to sign in again\n- validate input", ` +
+		`"breaking": true, "footers": [{"token": "Refs", "value": "#12"}, {"token": "breaking change", "value": "sessions expire"}]}`
+
+	var schema requestedSchema
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResponseFormat struct {
+				JSONSchema struct {
+					Schema requestedSchema `json:"schema"`
+				} `json:"json_schema"`
+			} `json:"response_format"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		schema = body.ResponseFormat.JSONSchema.Schema
+		respondWith(content)(w, r)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key", server.URL)
+	client.Mode = ModeJSONSchema
+	result, err := GenerateCommitMessage(context.Background(), client, testPrompt, Options{
+		Models:   []string{"test-model"},
+		Detailed: true,
+		Scopes:   []string{"auth", "db"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	want := "feat(auth)!: add login form\n\n" +
+		"- add a login form that remembers the user between visits so they don't\n" +
+		"  have to sign in again\n" +
+		"- validate input\n\n" +
+		"Refs: #12\n" +
+		"BREAKING CHANGE: sessions expire"
+	if result.Message != want {
+		t.Errorf("Message = %q, want %q", result.Message, want)
+	}
+	if got := schema.Properties["scope"].Enum; !slices.Equal(got, []string{"auth", "db", ""}) {
+		t.Errorf("Expected the scopes to be sent as an enum, got %v", got)
+	}
+}
+
+func TestGenerateCommitMessage_Ticket(t *testing.T) {
+	client, _ := modelServer(t, map[string]http.HandlerFunc{
+		"test-model": respondWith(`{"type": "feat", "scope": "", "subject": "add login", "breaking": false, "footers": []}`),
+	})
+
+	for placement, want := range map[convention.Placement]string{
+		convention.PlaceFooter:  "feat: add login\n\nRefs: PROJ-1234",
+		convention.PlaceSubject: "[PROJ-1234] feat: add login",
+	} {
+		var checked string
+		result, err := GenerateCommitMessage(context.Background(), client, testPrompt, Options{
+			Models:          []string{"test-model"},
+			Ticket:          "PROJ-1234",
+			TicketPlacement: placement,
+			Check:           func(message string) error { checked = message; return nil },
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if result.Message != want {
+			t.Errorf("%s: Message = %q, want %q", placement, result.Message, want)
+		}
+		if checked != want {
+			t.Errorf("%s: Expected the message to be checked with its ticket, got %q", placement, checked)
+		}
+	}
+}
+
+func TestGenerateCommitMessage_Revises(t *testing.T) {
+	answers := []string{
+		`{"type": "fix", "scope": "", "subject": "fixed the crash", "breaking": false, "footers": []}`,
+		`{"type": "fix", "scope": "", "subject": "fix the crash", "breaking": false, "footers": []}`,
+	}
+	var requests [][]struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body.Messages)
+		respondWith(answers[min(len(requests), len(answers))-1])(w, r)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key", server.URL)
+	client.Mode = ModeJSONSchema
+	var revised []string
+	opts := Options{
+		Models: []string{"test-model"},
+		Check: func(message string) error {
+			if strings.Contains(message, "fixed") {
+				return errors.New("subject isn't in the imperative mood")
+			}
+			return nil
+		},
+		MaxRevisions: 2,
+		OnRevise:     func(err error) { revised = append(revised, err.Error()) },
+	}
+	result, err := GenerateCommitMessage(context.Background(), client, testPrompt, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if result.Message != "fix: fix the crash" {
+		t.Errorf("Expected the revised message, got %q", result.Message)
+	}
+	if len(requests) != 2 || len(revised) != 1 {
+		t.Fatalf("Expected one revision, got %d requests and revisions %v", len(requests), revised)
+	}
+	// system, original prompt, the rejected answer and the feedback
+	second := requests[1]
+	if len(second) != 4 || second[1].Content != testPrompt.User || !strings.Contains(second[2].Content, `"subject":"fixed the crash"`) ||
+		!strings.Contains(second[3].Content, "fix: fixed the crash") ||
+		!strings.Contains(second[3].Content, "imperative mood") {
+		t.Errorf("Unexpected revision request: %+v", second)
+	}
+
+	// the last message is kept once the revisions run out
+	answers = answers[:1]
+	requests = nil
+	result, err = GenerateCommitMessage(context.Background(), client, testPrompt, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Message != "fix: fixed the crash" || len(requests) != 3 {
+		t.Errorf("Expected 3 attempts ending with the rejected message, got %q after %d", result.Message, len(requests))
+	}
+}
@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// errInvalidResponse marks a response that didn't match the requested schema.
+var errInvalidResponse = errors.New("response did not match the requested schema")
+
+// shouldFallback reports whether another model might succeed where the one
+// that returned err failed: the provider stayed unavailable after retrying,
+// or the model can't produce structured output or handle a prompt this long.
+func shouldFallback(err error) bool {
+	if errors.Is(err, errInvalidResponse) {
+		return true
+	}
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		// timeouts and transport errors
+		return true
+	}
+	if isRetryableStatus(apiErr.StatusCode) {
+		return true
+	}
+	return isContextLengthError(apiErr) || isSchemaError(apiErr)
+}
+
+func isContextLengthError(err *openai.Error) bool {
+	if err.Code == "context_length_exceeded" || err.StatusCode == http.StatusRequestEntityTooLarge {
+		return true
+	}
+	return containsAny(strings.ToLower(err.Message),
+		"context length", "context_length", "context window", "maximum context", "too many tokens", "prompt is too long")
+}
+
+func isSchemaError(err *openai.Error) bool {
+	if err.StatusCode != http.StatusBadRequest && err.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	if err.Param == "response_format" {
+		return true
+	}
+	return containsAny(strings.ToLower(err.Message),
+		"response_format", "json_schema", "structured output", "schema")
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
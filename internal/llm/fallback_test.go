@@ -0,0 +1,163 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kabilan108/diffgpt/internal/prompt"
+	"github.com/openai/openai-go"
+)
+
+var testPrompt = prompt.Prompt{System: "test system prompt", User: "test diff"}
+
+// modelServer answers each request with the handler registered for the
+// requested model, recording the models in the order they were asked.
+func modelServer(t *testing.T, handlers map[string]http.HandlerFunc) (*Client, *[]string) {
+	t.Helper()
+	var asked []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		asked = append(asked, body.Model)
+		handlers[body.Model](w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	recordSleeps(t)
+	client := NewClient("test-key", server.URL)
+	client.Retry.MaxAttempts = 1
+	// a single output mode, so each model is asked once
+	client.Mode = ModeJSONSchema
+	return client, &asked
+}
+
+func respondWith(content string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := json.Marshal(map[string]any{
+			"id":      "chatcmpl-test",
+			"object":  "chat.completion",
+			"created": 0,
+			"model":   "test-model",
+			"choices": []map[string]any{{
+				"index": 0, "finish_reason": "stop",
+				"message": map[string]string{"role": "assistant", "content": content},
+			}},
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
+
+func failWith(status int, apiErr string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, fmt.Sprintf(`{"error": %s}`, apiErr), status)
+	}
+}
+
+func TestGenerateCommitMessage_Fallback(t *testing.T) {
+	success := respondWith(`{"type": "fix", "scope": "", "subject": "fall back", "breaking": false, "footers": []}`)
+
+	tests := []struct {
+		name    string
+		primary http.HandlerFunc
+	}{
+		{"overloaded", failWith(http.StatusServiceUnavailable, `{"message": "overloaded"}`)},
+		{"rate limited", failWith(http.StatusTooManyRequests, `{"message": "slow down"}`)},
+		{"context length", failWith(http.StatusBadRequest,
+			`{"message": "This model's maximum context length is 8192 tokens", "code": "context_length_exceeded"}`)},
+		{"schema rejected", failWith(http.StatusBadRequest,
+			`{"message": "Invalid parameter", "param": "response_format"}`)},
+		{"invalid response", respondWith("Sure! Here is your commit message: fix stuff")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, asked := modelServer(t, map[string]http.HandlerFunc{"primary": tt.primary, "backup": success})
+
+			var fellBack []string
+			result, err := GenerateCommitMessage(context.Background(), client, testPrompt, Options{
+				Models: []string{"primary", "backup"},
+				OnFallback: func(failed, next string, err error) {
+					fellBack = append(fellBack, failed+" -> "+next)
+				},
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result.Message != "fix: fall back" || result.Model != "backup" {
+				t.Errorf("Unexpected result: %+v", result)
+			}
+			if fmt.Sprint(*asked) != "[primary backup]" {
+				t.Errorf("Expected both models to be asked, got %v", *asked)
+			}
+			if fmt.Sprint(fellBack) != "[primary -> backup]" {
+				t.Errorf("Expected one fallback, got %v", fellBack)
+			}
+		})
+	}
+}
+
+func TestGenerateCommitMessage_PrimarySucceeds(t *testing.T) {
+	client, asked := modelServer(t, map[string]http.HandlerFunc{
+		"primary": respondWith(`{"type": "feat", "scope": "", "subject": "primary", "breaking": false, "footers": []}`),
+	})
+
+	result, err := GenerateCommitMessage(context.Background(), client, testPrompt, Options{
+		Models: []string{"primary", "backup"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Model != "primary" || len(*asked) != 1 {
+		t.Errorf("Expected only the primary model to be asked, got %+v after %v", result, *asked)
+	}
+}
+
+func TestGenerateCommitMessage_NoFallbackOnAuthError(t *testing.T) {
+	client, asked := modelServer(t, map[string]http.HandlerFunc{
+		"primary": failWith(http.StatusUnauthorized, `{"message": "invalid api key"}`),
+	})
+
+	_, err := GenerateCommitMessage(context.Background(), client, testPrompt, Options{
+		Models: []string{"primary", "backup"},
+	})
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected the auth error, got: %v", err)
+	}
+	if fmt.Sprint(*asked) != "[primary]" {
+		t.Errorf("Expected only the primary model to be asked, got %v", *asked)
+	}
+}
+
+func TestGenerateCommitMessage_AllModelsFail(t *testing.T) {
+	overloaded := failWith(http.StatusServiceUnavailable, `{"message": "overloaded"}`)
+	client, _ := modelServer(t, map[string]http.HandlerFunc{"a": overloaded, "b": overloaded, "c": overloaded})
+
+	_, err := GenerateCommitMessage(context.Background(), client, testPrompt, Options{
+		Models: []string{"a", "b", "c"},
+	})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	want := "tried 3 models (a: 503 overloaded; b: 503 overloaded; c: 503 overloaded)"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected %q in error, got: %v", want, err)
+	}
+}
+
+func TestGenerateCommitMessage_NoModels(t *testing.T) {
+	_, err := GenerateCommitMessage(context.Background(), NewClient("test-key", "http://127.0.0.1:0"), testPrompt, Options{})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+}
@@ -0,0 +1,177 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kabilan108/diffgpt/internal/convention"
+)
+
+// sseChunk formats a streamed chat completion chunk carrying content.
+func sseChunk(content string) string {
+	delta, _ := json.Marshal(map[string]any{
+		"id":      "chatcmpl-test",
+		"object":  "chat.completion.chunk",
+		"created": 0,
+		"model":   "test-model",
+		"choices": []map[string]any{{"index": 0, "delta": map[string]string{"content": content}}},
+	})
+	return fmt.Sprintf("data: %s\n\n", delta)
+}
+
+func TestGenerate_Stream(t *testing.T) {
+	content := `{"type": "feat", "scope": "", "subject": "add login", "body": "- add form", "breaking": false, "footers": []}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < len(content); i += 5 {
+			fmt.Fprint(w, sseChunk(content[i:min(i+5, len(content))]))
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	var out strings.Builder
+	client := NewClient("test-key", server.URL)
+	result, err := Generate[StructuredCommit](
+		context.Background(), client, "test-model", "detailed_commit", "test description",
+		"test prompt", "test system prompt", nil, &out,
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	conv, _ := convention.Get(convention.Default)
+	if got, want := conv.Format(result.message()), "feat: add login\n\n- add form"; got != want {
+		t.Errorf("Expected the assembled message %q, got %q", want, got)
+	}
+	if want := "add login\n\n- add form\n"; out.String() != want {
+		t.Errorf("Expected streamed output %q, got %q", want, out.String())
+	}
+}
+
+func TestGenerate_StreamRetriedAttemptSeparated(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/event-stream")
+		if requests == 1 {
+			// a truncated response sends generate on to the next output mode
+			fmt.Fprint(w, sseChunk(`{"type": "feat", "scope": "", "subject": "add lo`))
+		} else {
+			fmt.Fprint(w, sseChunk(`{"type": "feat", "scope": "", "subject": "add login", "body": "", "breaking": false, "footers": []}`))
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	var out strings.Builder
+	client := NewClient("test-key", server.URL)
+	result, err := Generate[StructuredCommit](
+		context.Background(), client, "test-model", "commit", "test description",
+		"test prompt", "test system prompt", nil, &out,
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Type != "feat" || result.Subject != "add login" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if want := "add lo\n--- retrying ---\nadd login\n"; out.String() != want {
+		t.Errorf("Expected streamed output %q, got %q", want, out.String())
+	}
+}
+
+func TestGenerate_StreamCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, sseChunk(`{"type": "feat", "subject": "add`))
+		w.(http.Flusher).Flush()
+		// hang until the client goes away
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var out strings.Builder
+	client := NewClient("test-key", server.URL)
+	_, err := Generate[StructuredCommit](
+		ctx, client, "test-model", "commit", "test description",
+		"test prompt", "test system prompt", nil, &out,
+	)
+	if err == nil {
+		t.Fatal("Expected error after cancellation, got nil")
+	}
+	if !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("Expected a cancellation error, got: %v", err)
+	}
+}
+
+func TestGenerate_ReportsUsage(t *testing.T) {
+	usageChunk := `data: {"id": "chatcmpl-test", "object": "chat.completion.chunk", "created": 0, "model": "test-model", ` +
+		`"choices": [], "usage": {"prompt_tokens": 120, "completion_tokens": 8, "total_tokens": 128}}` + "\n\n"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Stream        bool `json:"stream"`
+			StreamOptions struct {
+				IncludeUsage bool `json:"include_usage"`
+			} `json:"stream_options"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if !body.Stream {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, strings.Replace(commitCompletion, `"choices"`,
+				`"usage": {"prompt_tokens": 100, "completion_tokens": 5, "total_tokens": 105}, "choices"`, 1))
+			return
+		}
+		if !body.StreamOptions.IncludeUsage {
+			t.Error("Expected streamed requests to ask for usage")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, sseChunk(`{"type": "fix", "scope": "", "subject": "retry", "body": "", "breaking": false, "footers": []}`))
+		fmt.Fprint(w, usageChunk)
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	var usages []Usage
+	client := NewClient("test-key", server.URL)
+	client.OnUsage = func(u Usage) { usages = append(usages, u) }
+
+	for _, stream := range []io.Writer{nil, io.Discard} {
+		_, err := Generate[StructuredCommit](
+			context.Background(), client, "test-model", "commit", "test description",
+			"test prompt", "test system prompt", nil, stream,
+		)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+
+	if len(usages) != 2 {
+		t.Fatalf("Expected usage for 2 requests, got %+v", usages)
+	}
+	for i, want := range []Usage{
+		{Model: "test-model", PromptTokens: 100, CompletionTokens: 5},
+		{Model: "test-model", PromptTokens: 120, CompletionTokens: 8},
+	} {
+		got := usages[i]
+		if got.Model != want.Model || got.PromptTokens != want.PromptTokens || got.CompletionTokens != want.CompletionTokens {
+			t.Errorf("Usage %d = %+v, want %+v", i, got, want)
+		}
+		if got.Latency <= 0 {
+			t.Errorf("Usage %d has no latency", i)
+		}
+	}
+}
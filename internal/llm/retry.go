@@ -0,0 +1,152 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// RetryPolicy controls how requests are retried. Rate limits (429), server
+// errors (5xx), timeouts and network failures are retried with exponential
+// backoff and jitter, honouring any Retry-After header sent by the server.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts, including the first
+	Timeout     time.Duration // per attempt, zero for no timeout
+	BaseDelay   time.Duration // delay before the first retry, doubled for every further retry
+	MaxDelay    time.Duration // upper bound for any delay, including Retry-After
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Timeout:     60 * time.Second,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+// sleep waits for d or until ctx is done; replaced in tests.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var errAttemptTimeout = errors.New("request timed out")
+
+// do runs call until it succeeds, fails with an error that isn't worth
+// retrying, or runs out of attempts. The final error summarizes every attempt
+// and wraps the last error.
+func (p RetryPolicy) do(ctx context.Context, call func(ctx context.Context) (string, error)) (string, error) {
+	attempts := max(p.MaxAttempts, 1)
+	var summary []string
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if p.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, p.Timeout)
+		}
+		content, err := call(attemptCtx)
+		timedOut := ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded)
+		cancel()
+
+		if err == nil {
+			return content, nil
+		}
+		if ctx.Err() != nil {
+			return "", err
+		}
+		if timedOut {
+			err = fmt.Errorf("%w after %s: %w", errAttemptTimeout, p.Timeout, err)
+		}
+
+		retryable := isRetryable(err)
+		if !retryable || attempt >= attempts {
+			if attempt == 1 {
+				return "", err
+			}
+			summary = append(summary, fmt.Sprintf("attempt %d: %s", attempt, describeError(err)))
+			return "", fmt.Errorf("giving up after %d attempts (%s): %w", attempt, strings.Join(summary, "; "), err)
+		}
+		summary = append(summary, fmt.Sprintf("attempt %d: %s", attempt, describeError(err)))
+
+		if err := sleep(ctx, p.delay(attempt, err)); err != nil {
+			return "", err
+		}
+	}
+}
+
+// delay returns how long to wait before the next attempt: the server's
+// Retry-After if it sent one, otherwise exponential backoff with jitter.
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	if d, ok := retryAfter(err); ok {
+		return min(d, p.MaxDelay)
+	}
+	backoff := p.BaseDelay << (attempt - 1)
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	// jitter between half and the full backoff so concurrent clients spread out
+	half := backoff / 2
+	return half + rand.N(half+1)
+}
+
+func isRetryable(err error) bool {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return isRetryableStatus(apiErr.StatusCode)
+	}
+	// timeouts and transport errors
+	return true
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500
+}
+
+// retryAfter extracts the delay requested by the server, if any.
+func retryAfter(err error) (time.Duration, bool) {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.Response == nil {
+		return 0, false
+	}
+	header := apiErr.Response.Header
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil && secs >= 0 {
+		return time.Duration(secs * float64(time.Second)), true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// describeError gives a short description of err for the attempt summary.
+func describeError(err error) string {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		if apiErr.Message != "" {
+			return fmt.Sprintf("%d %s", apiErr.StatusCode, apiErr.Message)
+		}
+		return fmt.Sprintf("%d %s", apiErr.StatusCode, http.StatusText(apiErr.StatusCode))
+	}
+	if errors.Is(err, errAttemptTimeout) {
+		return errAttemptTimeout.Error()
+	}
+	return err.Error()
+}
@@ -0,0 +1,254 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kabilan108/diffgpt/internal/convention"
+	"github.com/openai/openai-go"
+)
+
+const commitCompletion = `{
+	"id": "chatcmpl-test",
+	"object": "chat.completion",
+	"created": 0,
+	"model": "test-model",
+	"choices": [{"index": 0, "finish_reason": "stop",
+		"message": {"role": "assistant", "content": "{\"type\": \"fix\", \"scope\": \"\", \"subject\": \"retry\", \"body\": \"\", \"breaking\": false, \"footers\": []}"}}]
+}`
+
+// failingServer answers the first failures requests with fail and every later
+// one with a successful completion, counting requests in calls.
+func failingServer(t *testing.T, failures int32, fail http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			fail(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, commitCompletion)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// recordSleeps replaces sleep so tests don't wait, returning the requested delays.
+func recordSleeps(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var delays []time.Duration
+	orig := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { sleep = orig })
+	return &delays
+}
+
+// hang stalls a response until the client gives up on it.
+func hang(r *http.Request) {
+	select {
+	case <-r.Context().Done():
+	case <-time.After(time.Second):
+	}
+}
+
+// generateCommit generates a commit and assembles it into a conventional
+// commit message.
+func generateCommit(client *Client) (string, error) {
+	r, err := Generate[StructuredCommit](
+		context.Background(), client, "test-model", "commit", "test description",
+		"test prompt", "test system prompt", nil, nil,
+	)
+	if err != nil {
+		return "", err
+	}
+	conv, _ := convention.Get(convention.Default)
+	return conv.Format(r.message()), nil
+}
+
+func TestRetry_RateLimitHonoursRetryAfter(t *testing.T) {
+	delays := recordSleeps(t)
+	server, calls := failingServer(t, 2, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		http.Error(w, `{"error": {"message": "slow down"}}`, http.StatusTooManyRequests)
+	})
+
+	client := NewClient("test-key", server.URL)
+	result, err := generateCommit(client)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result != "fix: retry" {
+		t.Errorf("Unexpected result: %q", result)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("Expected 3 requests, got %d", got)
+	}
+	want := []time.Duration{7 * time.Second, 7 * time.Second}
+	if fmt.Sprint(*delays) != fmt.Sprint(want) {
+		t.Errorf("Expected delays %v, got %v", want, *delays)
+	}
+}
+
+func TestRetry_ServerErrorsExhaustAttempts(t *testing.T) {
+	delays := recordSleeps(t)
+	server, calls := failingServer(t, 100, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"message": "overloaded"}}`, http.StatusServiceUnavailable)
+	})
+
+	client := NewClient("test-key", server.URL)
+	client.Retry = RetryPolicy{MaxAttempts: 4, BaseDelay: 100 * time.Millisecond, MaxDelay: 250 * time.Millisecond}
+	_, err := generateCommit(client)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("Expected 4 requests, got %d", got)
+	}
+
+	msg := err.Error()
+	if !strings.Contains(msg, "giving up after 4 attempts") {
+		t.Errorf("Expected attempt count in error, got: %s", msg)
+	}
+	for i := 1; i <= 4; i++ {
+		if !strings.Contains(msg, fmt.Sprintf("attempt %d: 503 overloaded", i)) {
+			t.Errorf("Expected attempt %d in error, got: %s", i, msg)
+		}
+	}
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected error to wrap the last API error, got: %v", err)
+	}
+
+	// backoff doubles from BaseDelay, capped at MaxDelay, with up to half jitter
+	bounds := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 250 * time.Millisecond}
+	if len(*delays) != len(bounds) {
+		t.Fatalf("Expected %d delays, got %v", len(bounds), *delays)
+	}
+	for i, d := range *delays {
+		if d < bounds[i]/2 || d > bounds[i] {
+			t.Errorf("Delay %d = %v, expected between %v and %v", i, d, bounds[i]/2, bounds[i])
+		}
+	}
+}
+
+func TestRetry_ClientErrorNotRetried(t *testing.T) {
+	delays := recordSleeps(t)
+	server, calls := failingServer(t, 100, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"message": "bad request"}}`, http.StatusBadRequest)
+	})
+
+	client := NewClient("test-key", server.URL)
+	client.Mode = ModeJSONSchema
+	_, err := generateCommit(client)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected 1 request, got %d", got)
+	}
+	if len(*delays) != 0 {
+		t.Errorf("Expected no delays, got %v", *delays)
+	}
+	if strings.Contains(err.Error(), "giving up") {
+		t.Errorf("Expected no attempt summary for a single attempt, got: %v", err)
+	}
+}
+
+func TestRetry_TimeoutRetried(t *testing.T) {
+	recordSleeps(t)
+	server, calls := failingServer(t, 1, func(w http.ResponseWriter, r *http.Request) {
+		hang(r)
+	})
+
+	client := NewClient("test-key", server.URL)
+	client.Retry = RetryPolicy{MaxAttempts: 2, Timeout: 50 * time.Millisecond}
+	result, err := generateCommit(client)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result != "fix: retry" {
+		t.Errorf("Unexpected result: %q", result)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Expected 2 requests, got %d", got)
+	}
+}
+
+func TestRetry_TimeoutSummary(t *testing.T) {
+	recordSleeps(t)
+	server, _ := failingServer(t, 100, func(w http.ResponseWriter, r *http.Request) {
+		hang(r)
+	})
+
+	client := NewClient("test-key", server.URL)
+	client.Retry = RetryPolicy{MaxAttempts: 2, Timeout: 20 * time.Millisecond}
+	_, err := generateCommit(client)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{"giving up after 2 attempts", "attempt 1: request timed out", "attempt 2: request timed out"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in error, got: %v", want, err)
+		}
+	}
+}
+
+func TestRetry_CancelStopsRetrying(t *testing.T) {
+	server, calls := failingServer(t, 100, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"message": "overloaded"}}`, http.StatusInternalServerError)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	orig := sleep
+	sleep = func(context.Context, time.Duration) error {
+		cancel()
+		return context.Canceled
+	}
+	t.Cleanup(func() { sleep = orig })
+
+	_, err := Generate[StructuredCommit](
+		ctx, NewClient("test-key", server.URL), "test-model", "commit", "test description",
+		"test prompt", "test system prompt", nil, nil,
+	)
+	if err == nil || !strings.Contains(err.Error(), "generation cancelled") {
+		t.Errorf("Expected cancellation error, got: %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected 1 request, got %d", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+		ok     bool
+	}{
+		{"seconds", http.Header{"Retry-After": {"3"}}, 3 * time.Second, true},
+		{"milliseconds", http.Header{"Retry-After-Ms": {"1500"}, "Retry-After": {"9"}}, 1500 * time.Millisecond, true},
+		{"past date", http.Header{"Retry-After": {"Mon, 02 Jan 2006 15:04:05 GMT"}}, 0, true},
+		{"invalid", http.Header{"Retry-After": {"soon"}}, 0, false},
+		{"missing", http.Header{}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := &openai.Error{StatusCode: 429, Response: &http.Response{Header: tt.header}}
+			got, ok := retryAfter(err)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("retryAfter() = %v, %v; want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
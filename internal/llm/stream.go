@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// streamRenderer turns the incremental JSON of a structured commit response
+// into readable text, writing the decoded subject and body to w as they
+// arrive.
+type streamRenderer struct {
+	w       io.Writer
+	buf     strings.Builder
+	written string
+}
+
+func newStreamRenderer(w io.Writer) *streamRenderer {
+	return &streamRenderer{w: w}
+}
+
+// Write adds a chunk of raw model output and renders any new text.
+func (r *streamRenderer) Write(delta string) {
+	r.buf.WriteString(delta)
+	content := r.buf.String()
+
+	rendered := partialField(content, "subject")
+	if body := partialField(content, "body"); body != "" {
+		rendered += "\n\n" + body
+	}
+	// decoded text only ever grows, but be defensive about rewinds
+	if !strings.HasPrefix(rendered, r.written) {
+		return
+	}
+	if added := rendered[len(r.written):]; added != "" {
+		io.WriteString(r.w, added)
+		r.written = rendered
+	}
+}
+
+// Finish terminates the rendered output.
+func (r *streamRenderer) Finish() {
+	if r.written != "" {
+		io.WriteString(r.w, "\n")
+	}
+}
+
+// Reset prepares the renderer for a new response, such as a retry or
+// another output mode. Output already shown can't be taken back, so a
+// separator marks it as discarded.
+func (r *streamRenderer) Reset() {
+	if r.written != "" {
+		io.WriteString(r.w, "--- retrying ---\n")
+	}
+	r.buf.Reset()
+	r.written = ""
+}
+
+// fieldPatterns match the start of the value of each string field the
+// renderer shows. They are compiled once rather than for every chunk.
+var fieldPatterns = func() map[string]*regexp.Regexp {
+	patterns := map[string]*regexp.Regexp{}
+	for _, key := range []string{"subject", "body"} {
+		patterns[key] = regexp.MustCompile(`"` + regexp.QuoteMeta(key) + `"\s*:\s*"`)
+	}
+	return patterns
+}()
+
+// partialField extracts the decoded value of the string field key, one of
+// the keys of fieldPatterns, from a possibly incomplete JSON object,
+// returning as much of the value as has been received so far.
+func partialField(content, key string) string {
+	re, ok := fieldPatterns[key]
+	if !ok {
+		return ""
+	}
+	loc := re.FindStringIndex(content)
+	if loc == nil {
+		return ""
+	}
+
+	raw := content[loc[1]:]
+	end := len(raw)
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\\' {
+			// skip the escaped character, stopping before incomplete escapes
+			n := 2
+			if i+1 < len(raw) && raw[i+1] == 'u' {
+				n = 6
+			}
+			if i+n > len(raw) {
+				end = i
+				break
+			}
+			i += n - 1
+			continue
+		}
+		if raw[i] == '"' {
+			end = i
+			break
+		}
+	}
+
+	var value string
+	if err := json.Unmarshal([]byte(`"`+raw[:end]+`"`), &value); err != nil {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPartialField(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		key     string
+		want    string
+	}{
+		{"missing", `{"subj`, "subject", ""},
+		{"empty value", `{"subject": "`, "subject", ""},
+		{"partial value", `{"subject": "add lo`, "subject", "add lo"},
+		{"complete value", `{"subject": "add login", "body": "- a`, "subject", "add login"},
+		{"second field", `{"subject": "add login", "body": "- a`, "body", "- a"},
+		{"escaped newline", `{"body": "- a\n- b`, "body", "- a\n- b"},
+		{"incomplete escape", `{"body": "- a\`, "body", "- a"},
+		{"incomplete unicode escape", `{"subject": "caf\u00`, "subject", "caf"},
+		{"unicode escape", `{"subject": "café"}`, "subject", "café"},
+		{"escaped quote", `{"subject": "say \"hi\"", "body": ""}`, "subject", `say "hi"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := partialField(tt.content, tt.key); got != tt.want {
+				t.Errorf("partialField(%q, %q) = %q, want %q", tt.content, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamRenderer(t *testing.T) {
+	content := `{"type": "feat", "scope": "", "subject": "add login", "body": "- add form\n- add \"remember me\""}`
+
+	var out strings.Builder
+	r := newStreamRenderer(&out)
+	// feed the response a few bytes at a time, like a stream would
+	for i := 0; i < len(content); i += 3 {
+		r.Write(content[i:min(i+3, len(content))])
+	}
+	r.Finish()
+
+	want := "add login\n\n- add form\n- add \"remember me\"\n"
+	if out.String() != want {
+		t.Errorf("Expected rendered output %q, got %q", want, out.String())
+	}
+}
@@ -0,0 +1,192 @@
+// Package prompt renders the prompts sent to the llm from text/template files,
+// which users and repositories can override.
+package prompt
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/kabilan108/diffgpt/internal/config"
+	"github.com/kabilan108/diffgpt/internal/convention"
+	"github.com/kabilan108/diffgpt/internal/git"
+)
+
+// Templates are looked up by name in the repository's .diffgpt/prompts
+// directory, then in the prompts directory next to the user's config file,
+// falling back to the built-in templates.
+const (
+	SystemTemplate = "system.tmpl"
+	UserTemplate   = "user.tmpl"
+
+	repoPromptsDir = ".diffgpt/prompts"
+	userPromptsDir = "prompts"
+)
+
+//go:embed templates/*.tmpl
+var builtin embed.FS
+
+// Data is what templates can refer to.
+type Data struct {
+	Diff string
+	// Files are the paths of the files changed by the diff.
+	Files  []string
+	Stats  Stats
+	Branch string
+	// Repo is the repository's identity, e.g. github.com/owner/repo.
+	Repo     string
+	Detailed bool
+	Examples []config.Example
+	// Convention is the commit convention messages should follow.
+	Convention *convention.Convention
+}
+
+// Stats summarizes the size of the diff.
+type Stats struct {
+	Files     int
+	Additions int
+	Deletions int
+}
+
+// NewData returns the data for diff, with its file list and stats filled in
+// and the default convention.
+func NewData(diff string) Data {
+	s := git.SummarizeDiff(diff)
+	conv, _ := convention.Get(convention.Default)
+	return Data{
+		Diff:       diff,
+		Files:      s.Files,
+		Stats:      Stats{Files: len(s.Files), Additions: s.Additions, Deletions: s.Deletions},
+		Convention: conv,
+	}
+}
+
+// Templates holds the system and user prompt templates.
+type Templates struct {
+	System *template.Template
+	User   *template.Template
+	// Sources records where each template was loaded from, "built-in" for
+	// the defaults.
+	Sources map[string]string
+}
+
+// Exchange is a rendered example: a user message and the assistant's reply.
+type Exchange struct {
+	User      string
+	Assistant string
+}
+
+// Prompt is everything sent to the llm to generate a commit message.
+type Prompt struct {
+	System   string
+	Examples []Exchange
+	User     string
+}
+
+// Default returns the built-in templates.
+func Default() *Templates {
+	t, err := load(nil)
+	if err != nil {
+		panic(err) // the built-in templates are tested
+	}
+	return t
+}
+
+// Load finds the templates for the repository at repoRoot (which may be
+// empty) and checks that they only refer to fields that exist.
+func Load(repoRoot string) (*Templates, error) {
+	var dirs []string
+	if repoRoot != "" {
+		dirs = append(dirs, filepath.Join(repoRoot, repoPromptsDir))
+	}
+	configPath, err := config.GetConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	dirs = append(dirs, filepath.Join(filepath.Dir(configPath), userPromptsDir))
+	return load(dirs)
+}
+
+func load(dirs []string) (*Templates, error) {
+	t := &Templates{Sources: map[string]string{}}
+	for _, name := range []string{SystemTemplate, UserTemplate} {
+		tmpl, source, err := loadTemplate(name, dirs)
+		if err != nil {
+			return nil, err
+		}
+		t.Sources[name] = source
+		if name == SystemTemplate {
+			t.System = tmpl
+		} else {
+			t.User = tmpl
+		}
+	}
+	return t, nil
+}
+
+func loadTemplate(name string, dirs []string) (*template.Template, string, error) {
+	text, source := "", "built-in"
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			text, source = string(data), path
+			break
+		}
+		if !os.IsNotExist(err) {
+			return nil, "", fmt.Errorf("failed to read prompt template %s: %w", path, err)
+		}
+	}
+	if source == "built-in" {
+		data, err := builtin.ReadFile("templates/" + name)
+		if err != nil {
+			return nil, "", err
+		}
+		text = string(data)
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid prompt template %s: %w", source, err)
+	}
+	if err := validate(tmpl); err != nil {
+		return nil, "", fmt.Errorf("invalid prompt template %s: %w", source, err)
+	}
+	return tmpl, source, nil
+}
+
+// Render renders the prompt for data. Examples are rendered with the user
+// template so they are presented exactly like the diff being described.
+func (t *Templates) Render(data Data) (Prompt, error) {
+	var p Prompt
+	var err error
+	if p.System, err = execute(t.System, data); err != nil {
+		return Prompt{}, err
+	}
+	if p.User, err = execute(t.User, data); err != nil {
+		return Prompt{}, err
+	}
+	for _, ex := range data.Examples {
+		exData := NewData(ex.Diff)
+		exData.Repo = data.Repo
+		exData.Detailed = data.Detailed
+		exData.Convention = data.Convention
+		user, err := execute(t.User, exData)
+		if err != nil {
+			return Prompt{}, err
+		}
+		p.Examples = append(p.Examples, Exchange{User: user, Assistant: ex.Message})
+	}
+	return p, nil
+}
+
+func execute(tmpl *template.Template, data Data) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template %s: %w", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
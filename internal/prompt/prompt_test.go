@@ -0,0 +1,195 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kabilan108/diffgpt/internal/config"
+	"github.com/kabilan108/diffgpt/internal/convention"
+)
+
+const testDiff = `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1 +1,2 @@
+-old
++new
++more
+`
+
+func writeTemplate(t *testing.T, dir, name, text string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setupDirs points the user config directory at a temporary directory and
+// returns the user and repository prompt directories along with the repo root.
+func setupDirs(t *testing.T) (userDir, repoDir, repoRoot string) {
+	t.Helper()
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("HOME", configHome)
+	repoRoot = t.TempDir()
+	return filepath.Join(configHome, "diffgpt", userPromptsDir), filepath.Join(repoRoot, repoPromptsDir), repoRoot
+}
+
+func TestDefault_Render(t *testing.T) {
+	data := NewData(testDiff)
+	data.Examples = []config.Example{{Diff: "example diff", Message: "feat: example"}}
+
+	p, err := Default().Render(data)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	if !strings.HasPrefix(p.System, "You are an expert programmer") {
+		t.Errorf("Unexpected system prompt: %q", p.System)
+	}
+	if want := "Generate a commit message for the following diff:\n```diff\n" + testDiff + "\n```"; p.User != want {
+		t.Errorf("User = %q, want %q", p.User, want)
+	}
+	want := Exchange{
+		User:      "Generate a commit message for the following diff:\n```diff\nexample diff\n```",
+		Assistant: "feat: example",
+	}
+	if len(p.Examples) != 1 || p.Examples[0] != want {
+		t.Errorf("Examples = %+v, want [%+v]", p.Examples, want)
+	}
+}
+
+func TestDefault_RenderConvention(t *testing.T) {
+	data := NewData(testDiff)
+	if !strings.Contains(mustRender(t, data).System, "Conventional Commits") {
+		t.Error("Expected the default system prompt to ask for conventional commits")
+	}
+
+	data.Convention, _ = convention.Get("kernel")
+	system := mustRender(t, data).System
+	if !strings.Contains(system, data.Convention.Guidance) || strings.Contains(system, "Conventional Commits") {
+		t.Errorf("Expected the system prompt to follow the kernel convention, got %q", system)
+	}
+}
+
+func mustRender(t *testing.T, data Data) Prompt {
+	t.Helper()
+	p, err := Default().Render(data)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	return p
+}
+
+func TestNewData(t *testing.T) {
+	data := NewData(testDiff)
+	if strings.Join(data.Files, ",") != "main.go" {
+		t.Errorf("Files = %v", data.Files)
+	}
+	if data.Stats != (Stats{Files: 1, Additions: 2, Deletions: 1}) {
+		t.Errorf("Stats = %+v", data.Stats)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	userDir, repoDir, repoRoot := setupDirs(t)
+	writeTemplate(t, userDir, SystemTemplate, "user system")
+	writeTemplate(t, userDir, UserTemplate, "user {{.Branch}}")
+	writeTemplate(t, repoDir, UserTemplate, "repo {{.Branch}} {{.Stats.Additions}} {{range .Files}}{{.}}{{end}}")
+
+	templates, err := Load(repoRoot)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := templates.Sources[SystemTemplate]; got != filepath.Join(userDir, SystemTemplate) {
+		t.Errorf("system template loaded from %s", got)
+	}
+	if got := templates.Sources[UserTemplate]; got != filepath.Join(repoDir, UserTemplate) {
+		t.Errorf("user template loaded from %s", got)
+	}
+
+	data := NewData(testDiff)
+	data.Branch = "feature/login"
+	p, err := templates.Render(data)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	if p.System != "user system" || p.User != "repo feature/login 2 main.go" {
+		t.Errorf("Unexpected prompt: %+v", p)
+	}
+
+	// without a repository only the user's templates apply
+	templates, err = Load("")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := templates.Sources[UserTemplate]; got != filepath.Join(userDir, UserTemplate) {
+		t.Errorf("user template loaded from %s", got)
+	}
+}
+
+func TestLoad_BuiltIn(t *testing.T) {
+	_, _, repoRoot := setupDirs(t)
+
+	templates, err := Load(repoRoot)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	for _, name := range []string{SystemTemplate, UserTemplate} {
+		if templates.Sources[name] != "built-in" {
+			t.Errorf("%s loaded from %s, expected the built-in template", name, templates.Sources[name])
+		}
+	}
+}
+
+func TestLoad_ValidFields(t *testing.T) {
+	_, repoDir, repoRoot := setupDirs(t)
+	writeTemplate(t, repoDir, UserTemplate, `{{define "stats"}}{{.Additions}}/{{.Deletions}}{{end}}`+
+		`{{range $i, $e := .Examples}}{{$i}} {{$e.Message}} {{$.Branch}}{{end}}`+
+		`{{with .Convention}}{{.Name}} {{(.WithTypes $.Files).Summary}}{{end}}`+
+		`{{if gt (len .Files) 1}}{{index .Files 0}}{{else}}{{template "stats" .Stats}}{{end}}`)
+
+	if _, err := Load(repoRoot); err != nil {
+		t.Errorf("Expected a template using only known fields to load, got: %v", err)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"unknown field", "{{.Ticket}}", "unknown field Ticket in Data"},
+		{"unknown nested field", "{{.Stats.Lines}}", "unknown field Lines in Stats"},
+		{"unknown field in a branch", "{{if .Detailed}}{{.Body}}{{end}}", "unknown field Body in Data"},
+		{"unknown example field", "{{range .Examples}}{{.Subject}}{{end}}", "unknown field Subject in config.Example"},
+		{"unknown field in an else branch", "{{if .Detailed}}{{else}}{{.Body}}{{end}}", "unknown field Body in Data"},
+		{"unknown field in an untaken branch", "{{if not .Detailed}}{{.Body}}{{end}}", "unknown field Body in Data"},
+		{"unknown field of a with", "{{with .Stats}}{{.Lines}}{{end}}", "unknown field Lines in Stats"},
+		{"unknown field of a variable", "{{$s := .Stats}}{{$s.Lines}}", "unknown field Lines in Stats"},
+		{"unknown field of a range variable", "{{range $i, $e := .Examples}}{{$e.Body}}{{end}}", "unknown field Body in config.Example"},
+		{"unknown field of the root", "{{range .Files}}{{$.Ticket}}{{end}}", "unknown field Ticket in Data"},
+		{"unknown field in a called template", `{{define "x"}}{{.Lines}}{{end}}{{template "x" .Stats}}`, "unknown field Lines in Stats"},
+		{"unknown field of the convention", "{{.Convention.Rules}}", "unknown field Rules in convention.Convention"},
+		{"syntax error", "{{.Diff", "invalid prompt template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, repoDir, repoRoot := setupDirs(t)
+			writeTemplate(t, repoDir, UserTemplate, tt.text)
+
+			_, err := Load(repoRoot)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), filepath.Join(repoDir, UserTemplate)) {
+				t.Errorf("Expected %q and the template path in error, got: %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package prompt
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// validate checks every field chain in tmpl, in every branch and in the
+// templates it calls, against the types of Data, so that references to fields
+// that don't exist are reported when the template is loaded rather than when
+// a branch of it happens to run. Chains whose type can't be known, such as
+// the results of functions, aren't checked.
+func validate(tmpl *template.Template) error {
+	c := &checker{tmpl: tmpl, seen: map[string]bool{}}
+	data := reflect.TypeOf(Data{})
+	return c.walk(tmpl.Tree.Root, data, map[string]reflect.Type{"$": data})
+}
+
+// checker walks a template's parse tree, tracking the type of dot and of
+// each variable. A nil type is unknown.
+type checker struct {
+	tmpl *template.Template
+	// seen records the templates already checked, by name and type of dot.
+	seen map[string]bool
+}
+
+func (c *checker) walk(node parse.Node, dot reflect.Type, vars map[string]reflect.Type) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := c.walk(child, dot, vars); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		t, err := c.pipe(n.Pipe, dot, vars)
+		declare(n.Pipe, t, vars)
+		return err
+	case *parse.IfNode:
+		return c.branch(&n.BranchNode, dot, vars, func(t reflect.Type) reflect.Type { return dot })
+	case *parse.WithNode:
+		return c.branch(&n.BranchNode, dot, vars, func(t reflect.Type) reflect.Type { return t })
+	case *parse.RangeNode:
+		return c.rangeNode(n, dot, vars)
+	case *parse.TemplateNode:
+		t, err := c.pipe(n.Pipe, dot, vars)
+		if err != nil {
+			return err
+		}
+		called := c.tmpl.Lookup(n.Name)
+		key := n.Name + " " + typeName(t)
+		if called == nil || called.Tree == nil || c.seen[key] {
+			// a missing template is reported when it is executed
+			return nil
+		}
+		c.seen[key] = true
+		return c.walk(called.Tree.Root, t, map[string]reflect.Type{"$": t})
+	}
+	return nil
+}
+
+// branch checks an if or with, whose body is run with the dot body returns
+// for the type of the pipeline.
+func (c *checker) branch(
+	n *parse.BranchNode, dot reflect.Type, vars map[string]reflect.Type, body func(reflect.Type) reflect.Type,
+) error {
+	scope := copyVars(vars)
+	t, err := c.pipe(n.Pipe, dot, scope)
+	if err != nil {
+		return err
+	}
+	declare(n.Pipe, t, scope)
+	if err := c.walk(n.List, body(t), scope); err != nil {
+		return err
+	}
+	return c.walk(n.ElseList, dot, copyVars(vars))
+}
+
+func (c *checker) rangeNode(n *parse.RangeNode, dot reflect.Type, vars map[string]reflect.Type) error {
+	scope := copyVars(vars)
+	t, err := c.pipe(n.Pipe, dot, scope)
+	if err != nil {
+		return err
+	}
+	// the variables are the key and element, not the value ranged over
+	key, elem := elemTypes(t)
+	switch decl := n.Pipe.Decl; len(decl) {
+	case 1:
+		scope[decl[0].Ident[0]] = elem
+	case 2:
+		scope[decl[0].Ident[0]], scope[decl[1].Ident[0]] = key, elem
+	}
+	if err := c.walk(n.List, elem, scope); err != nil {
+		return err
+	}
+	return c.walk(n.ElseList, dot, copyVars(vars))
+}
+
+// pipe checks a pipeline and returns the type of its value.
+func (c *checker) pipe(p *parse.PipeNode, dot reflect.Type, vars map[string]reflect.Type) (reflect.Type, error) {
+	if p == nil {
+		// {{template "name"}} runs the template with nil data
+		return nil, nil
+	}
+	var t reflect.Type
+	for _, cmd := range p.Cmds {
+		var err error
+		if t, err = c.command(cmd, dot, vars); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// declare records the type of the variables p declares or assigns.
+func declare(p *parse.PipeNode, t reflect.Type, vars map[string]reflect.Type) {
+	if p == nil {
+		return
+	}
+	for _, v := range p.Decl {
+		vars[v.Ident[0]] = t
+	}
+}
+
+func (c *checker) command(cmd *parse.CommandNode, dot reflect.Type, vars map[string]reflect.Type) (reflect.Type, error) {
+	var t reflect.Type
+	for i, arg := range cmd.Args {
+		argType, err := c.arg(arg, dot, vars)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			t = argType
+		}
+	}
+	return t, nil
+}
+
+// arg checks an argument of a command and returns its type.
+func (c *checker) arg(node parse.Node, dot reflect.Type, vars map[string]reflect.Type) (reflect.Type, error) {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return dot, nil
+	case *parse.FieldNode:
+		return c.fields(n, dot, n.Ident)
+	case *parse.VariableNode:
+		return c.fields(n, vars[n.Ident[0]], n.Ident[1:])
+	case *parse.ChainNode:
+		t, err := c.arg(n.Node, dot, vars)
+		if err != nil {
+			return nil, err
+		}
+		return c.fields(n, t, n.Field)
+	case *parse.PipeNode:
+		t, err := c.pipe(n, dot, vars)
+		declare(n, t, vars)
+		return t, err
+	}
+	// functions and constants
+	return nil, nil
+}
+
+// fields follows the chain of field or method names from t.
+func (c *checker) fields(node parse.Node, t reflect.Type, names []string) (reflect.Type, error) {
+	for _, name := range names {
+		if t == nil {
+			return nil, nil
+		}
+		next, ok := fieldType(t, name)
+		if !ok {
+			location, _ := c.tmpl.ErrorContext(node)
+			return nil, fmt.Errorf("%s: unknown field %s in %s", location, name, typeName(indirect(t)))
+		}
+		t = next
+	}
+	return t, nil
+}
+
+// fieldType returns the type of the field or the result of the method name
+// of t, as text/template would evaluate .name on a value of type t.
+func fieldType(t reflect.Type, name string) (reflect.Type, bool) {
+	for _, typ := range []reflect.Type{t, reflect.PointerTo(indirect(t))} {
+		if m, ok := typ.MethodByName(name); ok {
+			if m.Type.NumOut() == 0 {
+				return nil, true
+			}
+			return m.Type.Out(0), true
+		}
+	}
+	switch t = indirect(t); t.Kind() {
+	case reflect.Struct:
+		if f, ok := t.FieldByName(name); ok && f.IsExported() {
+			return f.Type, true
+		}
+	case reflect.Map:
+		return t.Elem(), true
+	case reflect.Interface:
+		return nil, true
+	}
+	return nil, false
+}
+
+// elemTypes returns the types of the keys and elements ranging over a value
+// of type t gives.
+func elemTypes(t reflect.Type) (key, elem reflect.Type) {
+	if t == nil {
+		return nil, nil
+	}
+	switch t = indirect(t); t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return reflect.TypeOf(0), t.Elem()
+	case reflect.Map:
+		return t.Key(), t.Elem()
+	case reflect.Chan:
+		return t.Elem(), t.Elem()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return t, t
+	}
+	return nil, nil
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func typeName(t reflect.Type) string {
+	if t == nil {
+		return "unknown"
+	}
+	return strings.TrimPrefix(t.String(), "prompt.")
+}
+
+func copyVars(vars map[string]reflect.Type) map[string]reflect.Type {
+	scope := make(map[string]reflect.Type, len(vars))
+	for name, t := range vars {
+		scope[name] = t
+	}
+	return scope
+}
@@ -0,0 +1,111 @@
+// Package score rates learned (diff, message) pairs so that only examples
+// worth imitating are kept.
+package score
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/kabilan108/diffgpt/internal/config"
+	"github.com/kabilan108/diffgpt/internal/convention"
+	"github.com/kabilan108/diffgpt/internal/git"
+)
+
+// Weights of the individual heuristics, summing to 1.
+const (
+	subjectWeight      = 0.35
+	conventionalWeight = 0.2
+	detailWeight       = 0.3
+	uniqueWeight       = 0.15
+)
+
+// Heuristic rates every example between 0 and 1. Examples are scored
+// together so that subjects repeated across the set can be penalized.
+func Heuristic(examples []config.Example) []float64 {
+	subjectCounts := make(map[string]int, len(examples))
+	for _, ex := range examples {
+		subjectCounts[normalizedSubject(ex.Message)]++
+	}
+
+	scores := make([]float64, len(examples))
+	for i, ex := range examples {
+		subject := subjectOf(ex.Message)
+		s := subjectWeight * subjectLengthScore(subject)
+		if convention.IsConventional(subject) {
+			s += conventionalWeight
+		}
+		s += detailWeight * detailScore(ex.Message, ex.Diff)
+		if subjectCounts[normalizedSubject(ex.Message)] == 1 {
+			s += uniqueWeight
+		}
+		scores[i] = round(s)
+	}
+	return scores
+}
+
+// subjectLengthScore favours subjects that are descriptive without wrapping.
+func subjectLengthScore(subject string) float64 {
+	n := len(subject)
+	switch {
+	case n < 10:
+		return 0
+	case n < 20:
+		return 0.5
+	case n <= 72:
+		return 1
+	case n <= 100:
+		return 0.5
+	default:
+		return 0
+	}
+}
+
+// detailScore compares the amount of explanation in the message with the size
+// of the change, so a one word message for a huge diff scores poorly while
+// small changes don't need a body.
+func detailScore(message, diff string) float64 {
+	words := len(strings.Fields(message))
+	summary := git.SummarizeDiff(diff)
+	expected := 2 + 2*math.Sqrt(float64(summary.Additions+summary.Deletions))
+	return math.Min(1, float64(words)/expected)
+}
+
+func subjectOf(message string) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return strings.TrimSpace(subject)
+}
+
+func normalizedSubject(message string) string {
+	return strings.ToLower(subjectOf(message))
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// Curate returns the examples scoring at least minScore, limited to the keep
+// highest scoring ones if keep is positive. The original order is preserved.
+func Curate(examples []config.Example, keep int, minScore float64) []config.Example {
+	kept := make([]int, 0, len(examples))
+	for i, ex := range examples {
+		if ex.Score >= minScore {
+			kept = append(kept, i)
+		}
+	}
+
+	if keep > 0 && len(kept) > keep {
+		// stable so that newer examples win ties
+		sort.SliceStable(kept, func(a, b int) bool {
+			return examples[kept[a]].Score > examples[kept[b]].Score
+		})
+		kept = kept[:keep]
+		sort.Ints(kept)
+	}
+
+	curated := make([]config.Example, 0, len(kept))
+	for _, i := range kept {
+		curated = append(curated, examples[i])
+	}
+	return curated
+}
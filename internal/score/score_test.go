@@ -0,0 +1,82 @@
+package score
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kabilan108/diffgpt/internal/config"
+)
+
+func bigDiff(lines int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/main.go\n+++ b/main.go\n@@ -0,0 +1,%d @@\n", lines)
+	for i := 0; i < lines; i++ {
+		b.WriteString("+line\n")
+	}
+	return b.String()
+}
+
+func TestHeuristic(t *testing.T) {
+	examples := []config.Example{
+		{Message: "feat(api): add pagination to the list endpoint", Diff: bigDiff(4)},
+		{Message: "fix", Diff: bigDiff(2000)},
+		{Message: "update readme", Diff: bigDiff(1)},
+		{Message: "update readme", Diff: bigDiff(1)},
+	}
+
+	scores := Heuristic(examples)
+	if len(scores) != len(examples) {
+		t.Fatalf("Expected %d scores, got %d", len(examples), len(scores))
+	}
+
+	if scores[0] != 1 {
+		t.Errorf("Expected a perfect score for a good example, got %v", scores[0])
+	}
+	if scores[1] >= 0.2 {
+		t.Errorf("Expected a low score for 'fix' on a huge diff, got %v", scores[1])
+	}
+	if scores[2] >= scores[0] {
+		t.Errorf("Expected duplicate, non-conventional subject to score lower than %v, got %v", scores[0], scores[2])
+	}
+	for i, s := range scores {
+		if s < 0 || s > 1 {
+			t.Errorf("Score %d out of range: %v", i, s)
+		}
+	}
+}
+
+func TestCurate(t *testing.T) {
+	examples := []config.Example{
+		{Message: "a", Score: 0.5},
+		{Message: "b", Score: 0.9},
+		{Message: "c", Score: 0.2},
+		{Message: "d", Score: 0.9},
+		{Message: "e", Score: 0.7},
+	}
+
+	tests := []struct {
+		name     string
+		keep     int
+		minScore float64
+		want     []string
+	}{
+		{"keep all", 0, 0, []string{"a", "b", "c", "d", "e"}},
+		{"min score", 0, 0.6, []string{"b", "d", "e"}},
+		{"top three in original order", 3, 0, []string{"b", "d", "e"}},
+		{"top two with min score", 2, 0.95, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, ex := range Curate(examples, tt.keep, tt.minScore) {
+				got = append(got, ex.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Curate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}